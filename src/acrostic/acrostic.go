@@ -3,8 +3,11 @@ package acrostic
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -28,9 +31,27 @@ type Options struct {
 	KeywordFileName string
 	// OutFileName : 出力ファイル名
 	OutFileName string
+	// Format : 結果の出力形式(text, json, jsonl, html, svg, aozora, latex)
+	Format string
+	// Sort : 結果の並び順(空文字列ならば見つかった順，scoreならばスコアの高い順)
+	Sort string
+	// Top : 上位いくつの結果を出力するか(0ならばすべて)
+	Top int
+	// RenderCellSize : html, svgで1文字分のマスの大きさ(px)
+	RenderCellSize int
+	// RenderHighlightColor : html, svgで縦読み列の文字の色
+	RenderHighlightColor string
+	// RenderHighlightBackground : html, svgで縦読み列の背景の色
+	RenderHighlightBackground string
+	// RenderShowSurface : html, svgで元の文を下に表示する
+	RenderShowSurface bool
+	// Tategaki : text, htmlで縦書き(右から左)にする．縦読みのキーワードは横に並ぶ
+	Tategaki bool
+	// RenderRuby : htmlで，かなにした部分に元の表記をルビとして振る
+	RenderRuby bool
 	// StdoutResult : 結果を標準出力するかどうか
 	//StdoutResult bool
-	// Mode : 解析ツール（未実装）
+	// Mode : 解析ツール(jumanknp, knp-input)
 	Mode string
 
 	JumanCommand string
@@ -43,6 +64,8 @@ type Options struct {
 
 	// KnpOnly : KNPの実行だけして終了する
 	KnpOnly bool
+	// KnpInput : KNPを実行せずに，記録しておいたknp -tabの出力(ファイルまたはディレクトリ)を使う
+	KnpInput string
 	// CaseAnalysis : 格解析をする
 	CaseAnalysis bool
 	// Synonyms : WordNetを使って類語で言い換える
@@ -53,9 +76,9 @@ type Options struct {
 	SynonymsJapaneseOnly bool
 	// Width : 行の幅
 	Width int
-	// MaxWidth : 行の最大幅(-1でWidthと同じにする)
+	// MaxWidth : 行の最大幅(-1でWidthと同じにする．RunではWidth未満ならばWidthと同じ)
 	MaxWidth int
-	// Height : 最大行(未指定であれば(文字数/Width*2))
+	// Height : 最大行(-1ならば(文字数/Width*2)．Runでは0以下ならば自動)
 	Height int
 
 	// JumanDirectory : 活用形を取得するためのファイル
@@ -88,6 +111,8 @@ type Options struct {
 
 	// WARNING出力を無効にする
 	Quiet bool
+	// 標準出力に何も表示しない（Runから使うとき）
+	Silent bool
 	// INFO出力を有効にする
 	Verbose bool
 	// DEBUG出力を有効にする
@@ -120,6 +145,20 @@ type Options struct {
 	// 並列処理で計算する
 	Parallel bool
 
+	// Shard : 探索空間を分けたうちのどれを探索するか(i/n，iは1から数える．空ならば分けない)
+	Shard string
+	// ShardIndex : 探索するシャードの番号(0から数える)
+	ShardIndex int
+	// ShardCount : シャードの数(1ならば分けない)
+	ShardCount int
+
+	// Checkpoint : 探索を再開するためのチェックポイントのファイル名(空ならば書き出さない)
+	Checkpoint string
+	// CheckpointInterval : チェックポイントを書き出す間隔
+	CheckpointInterval time.Duration
+	// Resume : 再開するチェックポイントのファイル名(空ならば最初から探索する)
+	Resume string
+
 	// pproofをつかう
 	UsePproof bool
 
@@ -138,6 +177,9 @@ type Options struct {
 	// ArrangeMatrixで結果を書き出して一掃するタイミング
 	WipeOutLength int
 
+	// MemoSize : 解に至らなかった探索状態を覚えておく最大数(0ならば覚えない)
+	MemoSize int
+
 	// SynonymsVerb : 動詞の類義語を使うかどうか
 	SynonymsVerb bool
 
@@ -147,6 +189,31 @@ type Options struct {
 	// MatchLength : キーワードの文字数と出力文の行数を一致させる
 	MatchLength bool
 
+	// Direction : キーワードの読み方向(vertical, diagonal-left, diagonal-right)
+	Direction string
+
+	// Column : キーワードの先頭の文字を置く列(空ならばどこでもよい，head：行頭，last：行末，または列番号)
+	Column string
+
+	// Multi : キーワードファイルのすべてのキーワードを，ひとつの文章に同時に隠す
+	// キーワードファイルの各行は「キーワード 列」としてキーワードごとに列を固定できる
+	Multi bool
+
+	// Kinsoku : 折り返した行の先頭と末尾に禁則処理をする
+	Kinsoku bool
+
+	// KinsokuStart : 行頭に置かない文字
+	KinsokuStart string
+
+	// KinsokuEnd : 行末に置かない文字
+	KinsokuEnd string
+
+	// KinsokuHanging : 行頭に来るときに，前の行の行末の外にぶら下げる文字(空ならばぶら下げない)
+	KinsokuHanging string
+
+	// FullWidth : 半角の文字を全角にしてから解析する(行列の1マスを全角1文字にそろえる)
+	FullWidth bool
+
 	// PatternSize : 文パターンの最大サイズ
 	PatternSize int
 
@@ -157,6 +224,8 @@ type Options struct {
 	GCHeapSize uint64
 
 	Progress bool
+	// ProgressJSON : 進捗をJSONで1行に1件書き出すファイル名(-ならば標準エラー出力．空ならば書き出さない)
+	ProgressJSON string
 
 	//ProgressDepth int
 
@@ -173,12 +242,45 @@ type Options struct {
 	AllWordLength bool
 
 	UseKanji bool
+
+	// Timeout : 探索の制限時間(0ならば無制限)
+	Timeout time.Duration
+	// DeadlineString : 探索の期限
+	// 書式：RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "15:04:05", "15:04"
+	DeadlineString string
+	// Deadline : 探索の期限(ゼロ値ならば無制限)
+	Deadline time.Time
+
+	// Cache : 解析結果などを永続キャッシュに保存する
+	Cache bool
+	// CacheDatabase : 永続キャッシュのファイル名(空文字列ならばWordNetデータベースの隣)
+	CacheDatabase string
+
+	// AnalyzerWorkers : juman, knp, mecab, kakasiをそれぞれいくつ起動するか
+	AnalyzerWorkers int
+	// AnalyzerTimeout : juman, knp, mecab, kakasiの1回の要求の制限時間(0ならば無制限)
+	// 時間切れのときはコマンドを起動し直す
+	AnalyzerTimeout time.Duration
+
+	// Listen : serveで待ち受けるアドレス
+	Listen string
+	// MaxJobs : serveで同時に実行するジョブの数
+	MaxJobs int
+	// EvalGrid : evalで試すオプションの組み合わせ(「name=v1,v2;name=v1,v2」)
+	EvalGrid string
+
+	// Command : サブコマンド(空文字列ならば縦読み化をする)
+	Command string
+	// Args : サブコマンドの引数
+	Args []string
 }
 
 // Instance : 共通インスタンス
 type Instance struct {
 	// Juman KNP
 	JumanKnp *JumanKnp
+	// Analyzer : Options.Modeで選択された解析ツール
+	Analyzer Analyzer
 	// WordNet : 類語検索
 	WordNet *WordNet
 
@@ -191,6 +293,12 @@ type Instance struct {
 	Paraphrase *Paraphrase
 
 	Variables *Variables
+
+	// Cache : 永続キャッシュ(無効ならばnil)
+	Cache *Cache
+
+	// ProgressEvents : --progress-jsonの書き出し先(無効ならばnil)
+	ProgressEvents *ProgressEvents
 }
 
 // Acrostic : 構造の根
@@ -201,6 +309,16 @@ type Acrostic struct {
 	Instance   *Instance
 	Paragraphs []Paragraph
 	Found      bool
+	// Checkpoint : --checkpoint, --resumeのチェックポイント(nilならば使わない)
+	Checkpoint *Checkpoint
+	// KeywordColumns : --multiのときのキーワードごとの列(Keywordsと同じ順．空ならばOptions.Column)
+	KeywordColumns []string
+	// Truncated : 時間切れなどで探索を打ち切ったかどうか
+	Truncated bool
+	// Handler : 結果を受け取る関数（nilならばArrangeWriterで書き出す）
+	Handler ResultHandler
+	// results : --format json, html, svgのときに，最後にまとめて書き出す結果
+	results []Result
 }
 
 // NewOptions : constructor
@@ -213,15 +331,26 @@ func NewOptions() (*Options, error) {
 	flag.StringVarP(&o.KeywordFileName, "keyword", "k", "", T("f-keyword"))
 	flag.StringVarP(&o.TextFileName, "text", "t", "", T("f-text"))
 	flag.StringVarP(&o.OutFileName, "out", "o", "", T("f-out"))
+	flag.StringVar(&o.Format, "format", "text", T("f-format"))
+	flag.StringVar(&o.Sort, "sort", "", T("f-sort"))
+	flag.IntVar(&o.Top, "top", 0, T("f-top"))
+	flag.IntVar(&o.RenderCellSize, "cell-size", 32, T("f-cell-size"))
+	flag.StringVar(&o.RenderHighlightColor, "highlight-color", "#c00", T("f-highlight-color"))
+	flag.StringVar(&o.RenderHighlightBackground, "highlight-background", "#fee", T("f-highlight-background"))
+	flag.BoolVar(&o.RenderShowSurface, "show-surface", true, T("f-show-surface"))
+	flag.BoolVar(&o.Tategaki, "tategaki", false, T("f-tategaki"))
+	flag.BoolVar(&o.RenderRuby, "ruby", false, T("f-ruby"))
 	flag.IntVarP(&o.Width, "width", "w", 10, T("f-width"))
 	flag.IntVarP(&o.MaxWidth, "max", "m", -1, T("f-max"))
 	flag.IntVarP(&o.Height, "height", "h", -1, T("f-height"))
 
+	flag.StringVar(&o.Mode, "mode", "jumanknp", T("f-mode"))
 	flag.StringVar(&o.JumanCommand, "juman-command", "jumanpp", T("f-juman-command"))
 	flag.StringVar(&o.KnpCommand, "knp-command", "knp -tab -anaphora", T("f-knp-command"))
 	flag.StringVar(&o.KakasiCommand, "kakasi-command", "kakasi -JH -iutf-8 -outf-8", T("f-kakasi-command"))
 	flag.StringVar(&o.MeCabCommand, "mecab-command", "mecab -d /usr/local/lib/mecab/dic/mecab-ipadic-neologd -O yomi", T("f-mecab-command"))
 	flag.BoolVar(&o.KnpOnly, "knp-only", false, T("f-knp-only"))
+	flag.StringVar(&o.KnpInput, "knp-input", "", T("f-knp-input"))
 	flag.BoolVar(&o.CaseAnalysis, "case-analysis", true, T("f-case-analysis"))
 	flag.BoolVar(&o.Synonyms, "synonyms", true, T("f-synonyms"))
 	flag.StringVar(&o.WordNetDatabase, "wordnetdb", "third-party/wnjpn/wnjpn.db", T("f-wordnetdb"))
@@ -239,20 +368,34 @@ func NewOptions() (*Options, error) {
 	flag.StringVar(&o.WordNetLinkString, "wordnet-link", "synonyms,hype", T("f-wordnet-link"))
 	flag.StringVar(&o.ParaphraseDatabase, "paraphrase", "data/paraphrase.csv", T("f-paraphrase"))
 	flag.BoolVarP(&o.Parallel, "parallel", "j", true, T("f-parallel"))
+	flag.StringVar(&o.Shard, "shard", "", T("f-shard"))
+	flag.StringVar(&o.Checkpoint, "checkpoint", "", T("f-checkpoint"))
+	flag.DurationVar(&o.CheckpointInterval, "checkpoint-interval", time.Minute, T("f-checkpoint-interval"))
+	flag.StringVar(&o.Resume, "resume", "", T("f-resume"))
 	flag.BoolVar(&o.Confirm, "confirm", true, T("f-confirm"))
 	flag.BoolVar(&o.UsePproof, "use-pproof", true, T("f-pproof"))
 	flag.BoolVar(&o.ExtensionStructure, "extension-structure", false, T("f-extension-structure"))
 	flag.BoolVar(&o.EnableDeepCopy, "deep-copy", false, T("f-deep-copy"))
 	flag.BoolVar(&o.OutputEachPattern, "output-each", true, T("f-output-each"))
 	flag.IntVar(&o.WipeOutLength, "wipeout-length", 1000000, T("f-wipeout-length"))
+	flag.IntVar(&o.MemoSize, "memo-size", 1000000, T("f-memo-size"))
 	flag.BoolVar(&o.WipeOut, "wipeout", true, T("f-wipeout"))
 	flag.BoolVar(&o.SynonymsVerb, "synonyms-verb", false, T("f-synonyms-verb"))
 	flag.BoolVar(&o.UsePolite, "polite", true, T("f-polite"))
 	flag.BoolVarP(&o.MatchLength, "match-length", "l", true, T("f-match-length"))
+	flag.StringVar(&o.Direction, "direction", "vertical", T("f-direction"))
+	flag.StringVar(&o.Column, "column", "", T("f-column"))
+	flag.BoolVar(&o.Multi, "multi", false, T("f-multi"))
+	flag.BoolVar(&o.Kinsoku, "kinsoku", false, T("f-kinsoku"))
+	flag.StringVar(&o.KinsokuStart, "kinsoku-start", DefaultKinsokuStart, T("f-kinsoku-start"))
+	flag.StringVar(&o.KinsokuEnd, "kinsoku-end", DefaultKinsokuEnd, T("f-kinsoku-end"))
+	flag.StringVar(&o.KinsokuHanging, "kinsoku-hanging", "", T("f-kinsoku-hanging"))
+	flag.BoolVar(&o.FullWidth, "full-width", true, T("f-full-width"))
 	flag.IntVar(&o.PatternSize, "pattern-size", 1000000, T("f-pattern-size"))
 	flag.BoolVarP(&o.SwapSentences, "swap", "a", false, T("f-swap"))
 	flag.Uint64Var(&o.GCHeapSize, "gc", 10*1024*1024, T("f-gc"))
 	flag.BoolVar(&o.Progress, "progress", false, T("f-progress"))
+	flag.StringVar(&o.ProgressJSON, "progress-json", "", T("f-progress-json"))
 	//flag.IntVar(&o.ProgressDepth, "progress-depth", 20, "depth of progress")
 	flag.StringVar(&o.Language, "language", "", T("f-language"))
 	flag.BoolVar(&o.One, "one", false, T("f-one"))
@@ -262,13 +405,65 @@ func NewOptions() (*Options, error) {
 	flag.BoolVar(&o.AllWordLength, "all-word-length", true, T("f-all-word-length"))
 	flag.BoolVar(&o.UseKanji, "kanji", false, T("f-kanji"))
 	flag.BoolVar(&o.UseKana, "kana", true, T("f-kana"))
+	flag.DurationVar(&o.Timeout, "timeout", 0, T("f-timeout"))
+	flag.StringVar(&o.DeadlineString, "deadline", "", T("f-deadline"))
+	flag.BoolVar(&o.Cache, "cache", true, T("f-cache"))
+	flag.StringVar(&o.CacheDatabase, "cache-db", "", T("f-cache-db"))
+	flag.IntVar(&o.AnalyzerWorkers, "analyzer-workers", 1, T("f-analyzer-workers"))
+	flag.DurationVar(&o.AnalyzerTimeout, "analyzer-timeout", time.Minute, T("f-analyzer-timeout"))
+	flag.StringVar(&o.Listen, "listen", "localhost:8081", T("f-listen"))
+	flag.IntVar(&o.MaxJobs, "max-jobs", 2, T("f-max-jobs"))
+	flag.StringVar(&o.EvalGrid, "grid", "", T("f-grid"))
 	flag.Parse()
-	if o.KeywordFileName == "" {
+	if flag.NArg() > 0 {
+		o.Command = flag.Arg(0)
+		o.Args = flag.Args()[1:]
+	}
+	if o.KeywordFileName == "" && o.Command == "" {
 		return nil, errors.New("require keyword (-k)")
 	}
-	if o.TextFileName == "" {
+	if o.TextFileName == "" && o.Command == "" {
 		return nil, errors.New("require text (-t)")
 	}
+	if o.KnpInput != "" {
+		o.Mode = "knp-input"
+	}
+	switch o.Format {
+	case "text", "json", "jsonl", "html", "svg", "aozora", "latex":
+	default:
+		return nil, fmt.Errorf("format: only text, json, jsonl, html, svg, aozora or latex: %v", o.Format)
+	}
+	if o.Tategaki && (o.Format == "svg" || o.Format == "aozora" || o.Format == "latex") {
+		return nil, errors.New("tategaki: only with --format text or html")
+	}
+	if o.Sort != "" && o.Sort != "score" {
+		return nil, fmt.Errorf("sort: only score: %v", o.Sort)
+	}
+	if o.Top < 0 {
+		return nil, fmt.Errorf("top: must be 0 or more: %v", o.Top)
+	}
+	switch o.Direction {
+	case "", "vertical", "diagonal-left", "diagonal-right":
+	default:
+		return nil, fmt.Errorf("direction: only vertical, diagonal-left or diagonal-right: %v", o.Direction)
+	}
+	if validColumn(o.Column) == false {
+		return nil, fmt.Errorf("column: only head, last or a column number from 0: %v", o.Column)
+	}
+	if o.Checkpoint != "" || o.Resume != "" {
+		// 結果を探索の途中で書き出す形式でなければ，再開したときに失われる
+		if o.OutFileName == "" || (o.TextFormat() == false && o.Format != "jsonl") || o.OutputEachPattern == false {
+			return nil, errors.New("checkpoint: require -o with --format text or jsonl, and --output-each")
+		}
+		if o.Checkpoint == "" {
+			o.Checkpoint = o.Resume
+		}
+	}
+	if o.TextFormat() == false && o.OutFileName == "" {
+		// 標準出力をJSONだけにする
+		o.Silent = true
+		o.Confirm = false
+	}
 	// default log level is warning level
 	log.SetLevel(log.WarnLevel)
 	if o.Quiet {
@@ -300,6 +495,14 @@ func NewOptions() (*Options, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = o.parseDeadline()
+	if err != nil {
+		return nil, err
+	}
+	err = o.parseShard()
+	if err != nil {
+		return nil, err
+	}
 	return o, nil
 }
 
@@ -395,19 +598,164 @@ func (o *Options) parseWordNetLink() error {
 		return nil
 	}
 	for _, l := range strings.Split(o.WordNetLinkString, ",") {
-		o.WordNetLink = append(o.WordNetLink, NewWordNetLink(l))
+		link, err := NewWordNetLink(l)
+		if err != nil {
+			return err
+		}
+		o.WordNetLink = append(o.WordNetLink, link)
 	}
 	return nil
 }
 
+// TextFormat : 結果をテキストで出力するかどうか
+func (o *Options) TextFormat() bool {
+	return o.Format == "" || o.Format == "text"
+}
+
+// CollectFormat : 結果を集めて最後にまとめて出力する形式かどうか
+func (o *Options) CollectFormat() bool {
+	return o.Format == "json" || o.Format == "html" || o.Format == "svg" ||
+		o.Format == "aozora" || o.Format == "latex"
+}
+
+// DirectionStep : キーワードの次の文字が，1行下で何列ずれるか
+// vertical: 0, diagonal-left: -1, diagonal-right: 1
+func (o *Options) DirectionStep() int {
+	switch o.Direction {
+	case "diagonal-left":
+		return -1
+	case "diagonal-right":
+		return 1
+	}
+	return 0
+}
+
+// PinnedColumn : 幅widthの行列で，キーワードの先頭の文字を置く列
+// Columnが空ならば-1(どこでもよい)
+func (o *Options) PinnedColumn(width int) int {
+	return pinnedColumn(o.Column, width)
+}
+
+// pinnedColumn : 列の指定(head, last, 列番号)を幅widthの行列の列にする
+// 空または不正な指定ならば-1(どこでもよい)
+func pinnedColumn(column string, width int) int {
+	switch column {
+	case "":
+		return -1
+	case "head":
+		return 0
+	case "last":
+		return width - 1
+	}
+	n, err := strconv.Atoi(column)
+	if err != nil || n < 0 {
+		return -1
+	}
+	return n
+}
+
+// validColumn : 列の指定が正しいかどうか
+func validColumn(column string) bool {
+	switch column {
+	case "", "head", "last":
+		return true
+	}
+	n, err := strconv.Atoi(column)
+	return err == nil && n >= 0
+}
+
+// parseShard : Shard(i/n)からShardIndex, ShardCountを求める
+func (o *Options) parseShard() error {
+	o.ShardIndex = 0
+	o.ShardCount = 1
+	if o.Shard == "" {
+		return nil
+	}
+	f := strings.Split(o.Shard, "/")
+	if len(f) == 2 {
+		i, erri := strconv.Atoi(f[0])
+		n, errn := strconv.Atoi(f[1])
+		if erri == nil && errn == nil && 1 <= i && i <= n {
+			o.ShardIndex = i - 1
+			o.ShardCount = n
+			return nil
+		}
+	}
+	return fmt.Errorf("shard: must be i/n with 1 <= i <= n: %v", o.Shard)
+}
+
+// InShard : 探索の単位の通し番号unitが，このプロセスのシャードに入るかどうか
+// 単位は文パターンと最初の基本句のパターンの組で，順に各シャードへ配る
+func (o *Options) InShard(unit int) bool {
+	return o.ShardCount <= 1 || unit%o.ShardCount == o.ShardIndex
+}
+
+func (o *Options) parseDeadline() error {
+	return o.parseDeadlineAt(time.Now())
+}
+
+// parseDeadlineAt : nowを現在時刻としてDeadlineStringを読む
+// 時刻だけのときは，nowより後の最も近いその時刻にする．日付のある時刻が過ぎていればエラー
+func (o *Options) parseDeadlineAt(now time.Time) error {
+	if o.DeadlineString == "" {
+		return nil
+	}
+	layouts := []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "15:04:05", "15:04"}
+	for _, layout := range layouts {
+		t, err := time.ParseInLocation(layout, o.DeadlineString, time.Local)
+		if err != nil {
+			continue
+		}
+		if t.Year() == 0 {
+			// 時刻だけのときは今日．過ぎていれば明日
+			t = time.Date(now.Year(), now.Month(), now.Day(),
+				t.Hour(), t.Minute(), t.Second(), 0, time.Local)
+			if t.After(now) == false {
+				t = t.AddDate(0, 0, 1)
+			}
+		} else if t.After(now) == false {
+			return fmt.Errorf("deadline: already passed: %v", o.DeadlineString)
+		}
+		o.Deadline = t
+		return nil
+	}
+	return fmt.Errorf("deadline: cannot parse %v (example: 15:04, \"2006-01-02 15:04\", 2006-01-02T15:04:05+09:00)",
+		o.DeadlineString)
+}
+
+// WithDeadline : TimeoutとDeadlineのうち早いほうで終了するcontextを返す
+func (o *Options) WithDeadline(parent context.Context) (context.Context, context.CancelFunc) {
+	deadline := o.Deadline
+	if o.Timeout > 0 {
+		t := time.Now().Add(o.Timeout)
+		if deadline.IsZero() || t.Before(deadline) {
+			deadline = t
+		}
+	}
+	if deadline.IsZero() {
+		return context.WithCancel(parent)
+	}
+	return context.WithDeadline(parent, deadline)
+}
+
 func NewInstance(o *Options) (*Instance, error) {
 	log.Debug("initializing instances")
 	var err error
 	ret := new(Instance)
+	if o.Cache {
+		ret.Cache, err = NewCache(o)
+		if err != nil {
+			return nil, err
+		}
+	}
 	ret.JumanKnp, err = NewJumanKnp(o, ret)
 	if err != nil {
 		return nil, err
 	}
+	ret.Analyzer, err = NewAnalyzer(o, ret)
+	if err != nil {
+		return nil, err
+	}
 	ret.WordNet, err = NewWordNet(o, ret)
 	if err != nil {
 		return nil, err
@@ -431,10 +779,37 @@ func NewInstance(o *Options) (*Instance, error) {
 			return nil, err
 		}
 	}
+	if o.ProgressJSON != "" {
+		ret.ProgressEvents, err = NewProgressEvents(o.ProgressJSON)
+		if err != nil {
+			return nil, err
+		}
+	}
 	ret.Variables = &Variables{}
 	return ret, nil
 }
 
+// Close : 解析ツールのワーカー，WordNetデータベースとキャッシュを閉じる
+func (i *Instance) Close() error {
+	if i.JumanKnp != nil {
+		i.JumanKnp.Close()
+	}
+	if i.Kakasi != nil {
+		i.Kakasi.Close()
+	}
+	if i.MeCab != nil {
+		i.MeCab.Close()
+	}
+	var err error
+	if i.WordNet != nil {
+		err = i.WordNet.Close()
+	}
+	if cerr := i.Cache.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
 // NewVertical : constructor
 func NewVertical(o *Options) (*Acrostic, error) {
 	var err error
@@ -447,6 +822,14 @@ func NewVertical(o *Options) (*Acrostic, error) {
 	if err != nil {
 		return nil, err
 	}
+	if o.CollectFormat() {
+		// ひとつの文書にするため，最後にまとめて書き出す
+		v.results = make([]Result, 0)
+		v.Handler = func(r Result) error {
+			v.results = append(v.results, r)
+			return nil
+		}
+	}
 	return v, nil
 }
 
@@ -456,6 +839,7 @@ func readFileA1(filename string, slice *[][]rune) error {
 	if err != nil {
 		return err
 	}
+	defer f.Close()
 	scanner := bufio.NewScanner(f)
 	out := ""
 	for scanner.Scan() {
@@ -487,8 +871,27 @@ func readFileA2(filename string, slice *[][]rune) error {
 }
 
 // ReadKeyword : ファイルからキーワードを読み取る
+// --multiのときは，各行を「キーワード 列」として列の指定を分ける
 func (v *Acrostic) ReadKeyword() error {
-	return readFileA2(v.Options.KeywordFileName, &v.Keywords)
+	err := readFileA2(v.Options.KeywordFileName, &v.Keywords)
+	if err != nil || v.Options.Multi == false {
+		return err
+	}
+	v.KeywordColumns = make([]string, len(v.Keywords))
+	for i := range v.Keywords {
+		f := strings.Fields(string(v.Keywords[i]))
+		if len(f) == 0 || len(f) > 2 {
+			return fmt.Errorf("keyword: invalid line %v: %v", i+1, string(v.Keywords[i]))
+		}
+		v.Keywords[i] = []rune(f[0])
+		if len(f) == 2 {
+			if validColumn(f[1]) == false {
+				return fmt.Errorf("keyword: only head, last or a column number from 0: %v", f[1])
+			}
+			v.KeywordColumns[i] = f[1]
+		}
+	}
+	return nil
 }
 
 // ReadText : ファイルからテキストを読み取る
@@ -497,6 +900,34 @@ func (v *Acrostic) ReadText() error {
 	if err != nil {
 		return err
 	}
+	v.setHeight()
+	return nil
+}
+
+// toFullWidth : テキストとキーワードの半角の文字を全角にする
+func (v *Acrostic) toFullWidth() {
+	for i := range v.Text {
+		v.Text[i] = ToFullWidth(v.Text[i])
+	}
+	// Run(Request.Keywords)から渡されたキーワードを書き換えないように，コピーする
+	keywords := make([][]rune, len(v.Keywords))
+	for i := range v.Keywords {
+		keywords[i] = ToFullWidth(v.Keywords[i])
+	}
+	v.Keywords = keywords
+}
+
+// Nodes : すべての文章で探索した状態の数
+func (v *Acrostic) Nodes() int64 {
+	ret := int64(0)
+	for p := range v.Paragraphs {
+		ret += v.Paragraphs[p].Nodes
+	}
+	return ret
+}
+
+// setHeight : 最大行が未指定であれば，テキストから求める
+func (v *Acrostic) setHeight() {
 	if v.Options.Height == -1 {
 		// auto
 		lflen := 0
@@ -507,31 +938,44 @@ func (v *Acrostic) ReadText() error {
 		}
 		v.Options.Height = (len(v.Text[len(v.Text)-1]) * lflen) / v.Options.Width * 2
 	}
-	return nil
 }
 
 // Analyze : 解析する
 func (v *Acrostic) Analyze() error {
 	T, _ := i18n.Tfunc(v.Options.Language)
+	if v.Options.FullWidth {
+		v.toFullWidth()
+	}
 	for _, t := range v.Text {
 		//log.Debugf("Acrostic.Analyze: %v", string(t))
 		p := NewParagraph(v.Options, v.Instance, t, v.Keywords)
+		p.Handler = v.Handler
 		err := p.Analyze()
 		if err != nil {
 			return err
 		}
 		if v.Options.KnpOnly == false {
-			p.PrintAnalyzeResult()
+			if v.Options.Silent == false {
+				p.PrintAnalyzeResult()
+			}
 			keywords := make([][]rune, 0)
+			columns := make([]string, 0)
 			for k := range v.Keywords {
 				if p.CheckContainsKeyword(v.Keywords[k], k) {
 					keywords = append(keywords, v.Keywords[k])
+					if k < len(v.KeywordColumns) {
+						columns = append(columns, v.KeywordColumns[k])
+					}
 				}
 			}
 			if len(keywords) == 0 {
-				log.Fatalf(T("All keywords not found. Abort."))
+				log.Warnf(T("All keywords not found. Abort."))
+				return ErrKeywordNotFound
 			}
 			v.Keywords = keywords
+			if v.KeywordColumns != nil {
+				v.KeywordColumns = columns
+			}
 			if p.Options.Confirm {
 				TypeToContinue()
 			}
@@ -541,39 +985,157 @@ func (v *Acrostic) Analyze() error {
 	return nil
 }
 
+// Generate : 縦読み可能な文章を作成する
 func (v *Acrostic) Generate() error {
+	err := v.GenerateContext(context.Background())
+	if err != nil {
+		return err
+	}
+	if v.results != nil {
+		return v.OutputResults()
+	}
+	return nil
+}
+
+// OutputResults : --format json, html, svgのときに，集めた結果をひとつの文書として書き出す
+func (v *Acrostic) OutputResults() error {
+	w := os.Stdout
+	if v.Options.OutFileName != "" {
+		f, err := os.Create(v.Options.OutFileName)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return WriteResults(w, v.Options, v.results)
+}
+
+// WriteResults : Options.Sort, Options.Topで並べた結果を，Options.Formatでひとつの文書として書き出す
+// jsonlのときは1行に1件，text, jsonのときはJSONの配列にする
+func WriteResults(w io.Writer, o *Options, results []Result) error {
+	results = RankResultList(o, results)
+	switch o.Format {
+	case "html":
+		return RenderHTMLDocument(w, results, NewRenderStyle(o))
+	case "svg":
+		return RenderSVGDocument(w, results, NewRenderStyle(o))
+	case "aozora":
+		return RenderAozora(w, results)
+	case "latex":
+		return RenderLaTeX(w, results)
+	case "jsonl":
+		e := json.NewEncoder(w)
+		for i := range results {
+			err := e.Encode(NewResultRecord(results[i]))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	records := make([]ResultRecord, len(results))
+	for i := range results {
+		records[i] = NewResultRecord(results[i])
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(records)
+}
+
+// generate : p番目の文章にk番目のキーワード(--multiのときはすべてのキーワード)を隠す
+func (v *Acrostic) generate(ctx context.Context, p int, k int, w int) (bool, error) {
+	if v.Options.Multi {
+		return v.Paragraphs[p].GenerateMulti(ctx, v.Keywords, v.KeywordColumns, w)
+	}
+	return v.Paragraphs[p].Generate(ctx, v.Keywords[k], k, w)
+}
+
+// GenerateContext : ctxまたはTimeout, Deadlineで探索を打ち切る
+// 打ち切ったときは，それまでに見つかった結果を出力してTruncatedをtrueにする
+func (v *Acrostic) GenerateContext(ctx context.Context) error {
 	T, _ := i18n.Tfunc(v.Options.Language)
 	start := time.Now().UTC()
+	ctx, cancel := v.Options.WithDeadline(ctx)
+	defer cancel()
+	if v.Checkpoint == nil {
+		var err error
+		v.Checkpoint, err = NewCheckpoint(v.Options, v.Text, v.Keywords)
+		if err != nil {
+			return err
+		}
+	}
+	for p := range v.Paragraphs {
+		v.Paragraphs[p].Checkpoint = v.Checkpoint
+	}
 
-	for k := range v.Keywords {
+	// --multiのときは，すべてのキーワードをまとめて1回で探す
+	searches := len(v.Keywords)
+	if v.Options.Multi {
+		searches = 1
+	}
+	for k := 0; k < searches; k++ {
+		label := string(v.Keywords[k])
+		if v.Options.Multi {
+			all := make([]string, len(v.Keywords))
+			for i := range v.Keywords {
+				all[i] = string(v.Keywords[i])
+			}
+			label = strings.Join(all, " ")
+		}
 		for w := v.Options.Width; w <= v.Options.MaxWidth; w++ {
-			fmt.Printf("%v%2v: %v%v (%v: %v)\n",
-				color.FGreen, k, string(v.Keywords[k]), color.Reset, T("width"), w)
+			if ctx.Err() != nil {
+				v.Truncated = true
+				break
+			}
+			if v.Options.Silent == false {
+				fmt.Printf("%v%2v: %v%v (%v: %v)\n",
+					color.FGreen, k, label, color.Reset, T("width"), w)
+			}
 			for p := range v.Paragraphs {
+				if v.Checkpoint.SkipGenerate(k, w, p) {
+					// --resumeで，すでに探索を終えて書き出した
+					continue
+				}
+				v.Checkpoint.StartGenerate(k, w, p)
 				if v.Paragraphs[p].FoundBasicPhrase {
-					r, err := v.Paragraphs[p].Generate(v.Keywords[k], k, w)
+					r, err := v.generate(ctx, p, k, w)
 					if err != nil {
 						return err
 					}
+					if ctx.Err() != nil {
+						v.Truncated = true
+					}
 					if r {
 						v.Found = true
 						if v.Options.One {
 							break
 						}
 					}
+					if v.Truncated {
+						break
+					}
 				} else {
-					log.Fatal("invalid operation to call Generate")
+					return errors.New("invalid operation to call Generate: no basic phrase found")
 				}
 			}
-			if v.Options.One && v.Found {
+			if (v.Options.One && v.Found) || v.Truncated {
 				break
 			}
 		}
-		if v.Options.One && v.Found {
+		if (v.Options.One && v.Found) || v.Truncated {
 			break
 		}
 	}
-	if v.Options.Verbose || v.Options.Verbosely {
+	if v.Truncated {
+		log.Warnf("%v: %v", T("search truncated"), ctx.Err())
+		if err := v.Checkpoint.Save(); err != nil {
+			return err
+		}
+	} else if err := v.Checkpoint.Finish(); err != nil {
+		return err
+	}
+	if (v.Options.Verbose || v.Options.Verbosely) && v.Options.Silent == false {
 		elapsed := time.Since(start)
 		fmt.Printf(T("arrange process time")+": %v\n", elapsed)
 	}
@@ -0,0 +1,75 @@
+package acrostic
+
+import (
+	"fmt"
+
+	"github.com/noyuno/lgo/runes"
+)
+
+// Analyzer : 係り受け解析および格解析をするツール
+type Analyzer interface {
+	// Analyze : 文を解析して，文節ごとに`knp -tab`形式の行を返す
+	// 文節は*から始まり，基本句は+から始まる．EOSは含まない．
+	Analyze(text []rune) ([][][]rune, error)
+}
+
+// NewAnalyzer : Options.Modeで指定された解析ツールを作成する
+func NewAnalyzer(o *Options, i *Instance) (Analyzer, error) {
+	switch o.Mode {
+	case "", "jumanknp":
+		if i.JumanKnp == nil {
+			return nil, fmt.Errorf("analyzer %v: JumanKnp is not initialized", o.Mode)
+		}
+		return i.JumanKnp, nil
+	case "knp-input":
+		k, err := NewKnpInput(o)
+		if err != nil {
+			return nil, err
+		}
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown analyzer mode: %v", o.Mode)
+}
+
+// SplitKnp : knp -tabの出力を文節ごとに分割する
+func SplitKnp(v []rune) [][][]rune {
+	lfToken := []rune("\n")
+	phraseToken := []rune("*")
+	eosToken := []rune("EOS")
+	t := runes.Split(v, lfToken)
+
+	ret := make([][][]rune, 0)
+	phrase := make([][]rune, 0)
+	enable := false
+	for i := 0; i < len(t); i++ {
+		if len(t[i]) == 0 {
+			continue
+		}
+		if t[i][0] == phraseToken[0] {
+			if len(phrase) > 0 {
+				phrasec := make([][]rune, len(phrase))
+				for i := range phrase {
+					phrasec[i] = make([]rune, len(phrase[i]))
+					copy(phrasec[i], phrase[i])
+				}
+				phrase = phrase[:0]
+				ret = append(ret, phrasec)
+			}
+			enable = true
+		}
+		if enable {
+			if len(t[i]) < 3 || !runes.Compare(eosToken, t[i][0:3]) {
+				phrase = append(phrase, t[i])
+			}
+		}
+	}
+	if len(phrase) > 0 {
+		phrasec := make([][]rune, len(phrase))
+		for i := range phrase {
+			phrasec[i] = make([]rune, len(phrase[i]))
+			copy(phrasec[i], phrase[i])
+		}
+		ret = append(ret, phrasec)
+	}
+	return ret
+}
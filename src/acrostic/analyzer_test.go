@@ -0,0 +1,78 @@
+package acrostic
+
+import (
+	"testing"
+)
+
+// tAnalyzer : 決まったknp -tab出力を返すAnalyzer
+type tAnalyzer struct {
+	Output map[string]string
+}
+
+func (a *tAnalyzer) Analyze(text []rune) ([][][]rune, error) {
+	return SplitKnp([]rune(a.Output[string(text)])), nil
+}
+
+func tAnalyzerOptions() *Options {
+	return &Options{
+		UseKana:          true,
+		WordPatternLimit: 100,
+		AllWordLength:    true,
+	}
+}
+
+const tKnpMikan = `# S-ID:1 KNP:4.18
+* 1D <SM-主体><BGH:蜜柑/みかん>
++ 1D <BGH:蜜柑/みかん>
+みかん みかん みかん 名詞 6 普通名詞 1 * 0 * 0 "代表表記:蜜柑/みかん カテゴリ:植物" <自立>
+は は は 助詞 9 副助詞 2 * 0 * 0 NIL <付属>
+* -1D <用言:形>
++ -1D <用言:形>
+あまい あまい あまい 形容詞 3 * 0 イ形容詞アウオ段 18 基本形 2 "代表表記:甘い/あまい" <自立>
+。 。 。 特殊 1 句点 1 * 0 * 0 NIL <付属>
+EOS
+`
+
+func TestSentenceAnalyzer(t *testing.T) {
+	o := tAnalyzerOptions()
+	i := &Instance{
+		Analyzer: &tAnalyzer{Output: map[string]string{"みかんはあまい。": tKnpMikan}},
+	}
+	keywords := [][]rune{[]rune("みか")}
+	s := NewSentence(o, i, []rune("みかんはあまい。"), false, keywords)
+	end, err := s.Analyze(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if end != 2 {
+		t.Errorf("want end=2, but returned %v", end)
+	}
+	if len(s.Phrases) != 2 {
+		t.Fatalf("want 2 phrases, but returned %v", len(s.Phrases))
+	}
+	expected := []string{"みかんは", "あまい。"}
+	for i := range expected {
+		if string(s.BasicPhrases[i].Surface) != expected[i] {
+			t.Errorf("want BasicPhrases[%v]=%v, but returned %v",
+				i, expected[i], string(s.BasicPhrases[i].Surface))
+		}
+	}
+	if s.Phrases[0].Destination != 1 || s.Phrases[1].Destination != -1 {
+		t.Errorf("want destination [1 -1], but returned [%v %v]",
+			s.Phrases[0].Destination, s.Phrases[1].Destination)
+	}
+	if len(s.BasicPhrases[0].Pattern) == 0 {
+		t.Errorf("want patterns of %v", string(s.BasicPhrases[0].Surface))
+	}
+}
+
+func TestNewAnalyzer(t *testing.T) {
+	o := &Options{Mode: "unknown"}
+	if _, err := NewAnalyzer(o, &Instance{}); err == nil {
+		t.Errorf("want error for unknown mode")
+	}
+	o.Mode = "jumanknp"
+	if _, err := NewAnalyzer(o, &Instance{}); err == nil {
+		t.Errorf("want error for uninitialized JumanKnp")
+	}
+}
@@ -1,6 +1,7 @@
 package acrostic
 
 import (
+	"context"
 	"fmt"
 	"runtime"
 	"sort"
@@ -24,6 +25,16 @@ type Arrange struct {
 	Count       []int
 	Writer      *ArrangeWriter
 	WipedLength []int
+	// Truncated : 時間切れなどで探索を打ち切ったかどうか
+	Truncated bool
+	// Nodes : 探索した状態の数(文パターンすべての合計)
+	Nodes int64
+	// Hidden : --multiで同時に隠すキーワード(空ならばKeywordだけを探す)
+	Hidden []ArrangeHidden
+	// Checkpoint : --checkpoint, --resumeのチェックポイント(nilならば使わない)
+	Checkpoint *Checkpoint
+	// shardUnit : --shardで配る探索の単位の，次の文パターンの最初の通し番号
+	shardUnit int
 }
 
 type BasicPhraseArrange struct {
@@ -45,8 +56,8 @@ func NewArrange(o *Options, i *Instance, s []Sentence, t []rune, kn int, k []run
 	ret.Width = width
 	ret.Surfaces = make([][]rune, 0)
 	ret.Writer = NewArrangeWriter(ret.Options, ret.Number, ret.Keyword)
-	if ret.Number == 0 {
-		// ファイルを初期化
+	if ret.Number == 0 && o.Resume == "" {
+		// ファイルを初期化(再開するときは続きに書く)
 		err := ret.Writer.Truncate()
 		if err != nil {
 			return nil, err
@@ -176,7 +187,8 @@ func bpPatternLength(sentences []Sentence, swap bool, lim int) int64 {
 	return ret
 }
 
-func sentencePattern(array [][][]BasicPhrase, swap bool) chan []BasicPhrase {
+// sentencePattern : 文パターンを順に送る．ctxが終了したら送るのをやめてcを閉じる
+func sentencePattern(ctx context.Context, array [][][]BasicPhrase, swap bool) chan []BasicPhrase {
 	c := make(chan []BasicPhrase)
 	go func(c chan []BasicPhrase) {
 		defer close(c)
@@ -210,7 +222,11 @@ func sentencePattern(array [][][]BasicPhrase, swap bool) chan []BasicPhrase {
 						}
 						bp = append(bp, array[e[i]][v[i]]...)
 					}
-					c <- bp
+					select {
+					case c <- bp:
+					case <-ctx.Done():
+						return
+					}
 				}
 			}
 		} else {
@@ -236,14 +252,32 @@ func sentencePattern(array [][][]BasicPhrase, swap bool) chan []BasicPhrase {
 					}
 					bp = append(bp, array[i][v[i]]...)
 				}
-				c <- bp
+				select {
+				case c <- bp:
+				case <-ctx.Done():
+					return
+				}
 			}
 		}
 	}(c)
 	return c
 }
 
-func (a *Arrange) Arrange() (bool, error) {
+// patternSurface : 文パターンの表層．改行する基本句の前に\nと書く
+func patternSurface(bpa []BasicPhrase) []rune {
+	surface := []rune("")
+	for i := range bpa {
+		if bpa[i].NewLine && i != 0 {
+			surface = append(surface, []rune("\\n")...)
+		}
+		surface = append(surface, bpa[i].Surface...)
+	}
+	return surface
+}
+
+// Arrange : 文パターンごとに縦読みを探索する
+// ctxが終了したら探索を打ち切り，それまでに見つかった結果を出力してTruncatedをtrueにする
+func (a *Arrange) Arrange(ctx context.Context) (bool, error) {
 	//T, _ := i18n.Tfunc(a.Options.Language)
 
 	// 下準備
@@ -254,22 +288,28 @@ func (a *Arrange) Arrange() (bool, error) {
 	a.Count = make([]int, 0)
 	a.WipedLength = make([]int, 0)
 
+	// 途中でループを抜けたときに，sentencePatternのgoroutineを終わらせる
+	pctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	bpai := 0
-	for bpa := range sentencePattern(sentences, a.Options.SwapSentences) {
+	for bpa := range sentencePattern(pctx, sentences, a.Options.SwapSentences) {
+		if a.Checkpoint.SkipPattern(bpai) {
+			// --resumeで，すでに探索を終えて書き出した文パターン
+			a.shardPatterns(bpa)
+			a.WipedLength = append(a.WipedLength, 0)
+			a.Count = append(a.Count, 0)
+			a.Surfaces = append(a.Surfaces, []rune{})
+			bpai++
+			continue
+		}
 		if a.Options.OutputEachPattern {
 			a.WipedLength = append(a.WipedLength, 0)
 			a.Count = append(a.Count, 0)
-			mret, err := a.ArrangePattern(bpai, bpa)
+			mret, err := a.ArrangePattern(ctx, bpai, bpa)
 			if err != nil {
 				return false, err
 			}
-			surface := []rune("")
-			for i := range bpa {
-				if bpa[i].NewLine && i != 0 {
-					surface = append(surface, []rune("\\n")...)
-				}
-				surface = append(surface, bpa[i].Surface...)
-			}
+			surface := patternSurface(bpa)
 			a.Surfaces = append(a.Surfaces, surface)
 			_, err = a.Writer.OutputPattern(
 				a.Keyword, surface, bpai, mret, true, a.WipedLength[bpai], a.Width)
@@ -277,7 +317,10 @@ func (a *Arrange) Arrange() (bool, error) {
 				return false, err
 			}
 			a.Count[bpai] = len(mret)
-			if a.Options.One && len(mret) > 0 {
+			if a.Options.One && len(mret)+a.WipedLength[bpai] > 0 {
+				break
+			}
+			if a.Truncated {
 				break
 			}
 
@@ -288,13 +331,18 @@ func (a *Arrange) Arrange() (bool, error) {
 			//	fmt.Printf("found %v patterns\n", a.Count[bpai]+a.WipedLength[bpai])
 			//}
 		} else {
-			mret, err := a.ArrangePattern(bpai, bpa)
+			a.WipedLength = append(a.WipedLength, 0)
+			mret, err := a.ArrangePattern(ctx, bpai, bpa)
 			if err != nil {
 				return false, err
 			}
+			a.Surfaces = append(a.Surfaces, patternSurface(bpa))
 			a.Results = append(a.Results, mret)
 			a.Count = append(a.Count, len(mret))
-			if a.Options.One && len(mret) > 0 {
+			if a.Options.One && len(mret)+a.WipedLength[bpai] > 0 {
+				break
+			}
+			if a.Truncated {
 				break
 			}
 		}
@@ -325,7 +373,16 @@ func (a *Arrange) Arrange() (bool, error) {
 	return false, nil
 }
 
-func (a *Arrange) ArrangePattern(bpai int, bpa []BasicPhrase) ([]ArrangeMatrixResult, error) {
+func (a *Arrange) ArrangePattern(ctx context.Context, bpai int, bpa []BasicPhrase) ([]ArrangeMatrixResult, error) {
+	top, ok, err := a.topPatterns(bpai, bpa)
+	if err != nil {
+		return nil, err
+	}
+	if ok == false {
+		// このシャードで探索するパターンがないか，すべて探索を終えていた
+		a.WipedLength[bpai] = 0
+		return make([]ArrangeMatrixResult, 0), nil
+	}
 	o := fmt.Sprintf("%2v-%2v: ", a.Number, bpai)
 	for bpi, bp := range bpa {
 		if bp.NewLine && bpi != 0 {
@@ -333,11 +390,24 @@ func (a *Arrange) ArrangePattern(bpai int, bpa []BasicPhrase) ([]ArrangeMatrixRe
 		}
 		o += string(bp.Surface)
 	}
-	fmt.Println(o)
+	if a.Options.Silent == false {
+		fmt.Println(o)
+	}
 	//bplength := bpPatternLength(sentences, a.Options.SwapSentences,
 	//	a.Options.ProgressDepth)
 	progress := NewArrangeProgress(a.Options, bpa)
+	progress.Keyword = a.Number
+	progress.Width = a.Width
+	progress.Pattern = bpai
+	if a.Instance != nil {
+		progress.Events = a.Instance.ProgressEvents
+	}
 	progressid := progress.Add("main")
+	var scheduler *ArrangeScheduler
+	if a.Options.Parallel {
+		// 呼び出した側のgoroutineも探索するので，ワーカーはCPUの数より1つ少なくする
+		scheduler = NewArrangeScheduler(runtime.NumCPU()-1, progress)
+	}
 	progress.Start()
 
 	//mat := make([][]rune, 0)
@@ -353,15 +423,79 @@ func (a *Arrange) ArrangePattern(bpai int, bpa []BasicPhrase) ([]ArrangeMatrixRe
 	if err != nil {
 		return nil, err
 	}
-	err = am.Search([]int{}, 0)
+	am.Memo = NewArrangeMemo(a.Options.MemoSize)
+	am.TopPatterns = top
+	am.Checkpoint = a.Checkpoint
+	am.Scheduler = scheduler
+	if len(a.Hidden) > 0 {
+		am.Hidden = copyHidden(a.Hidden)
+		err = am.SearchMulti(ctx)
+	} else {
+		err = am.Search(ctx, []int{}, 0)
+	}
+	scheduler.Close()
 	progress.Stop()
+	a.Nodes += progress.Nodes
+	if scheduler != nil {
+		log.Debugf("scheduler: %v searches spawned", scheduler.Spawned)
+	}
+	if am.Memo != nil {
+		log.Debugf("memo: %v dead states, %v hits", am.Memo.Len(), am.Memo.Hits)
+	}
 	if err != nil {
 		return nil, err
 	}
+	if ctx.Err() != nil {
+		a.Truncated = true
+	}
 	a.WipedLength[bpai] = am.WipedLength
 	return am.MatrixResult, nil
 }
 
+// topPatterns : 最初の基本句のパターンごとに，探索するかどうか
+// --shardで他のシャードに配ったパターンと，--resumeで探索を終えていたパターンを除く．
+// すべて探索するときはnilを返す．探索するパターンがひとつもなければfalseを返す
+func (a *Arrange) topPatterns(bpai int, bpa []BasicPhrase) ([]bool, bool, error) {
+	ret, ok := a.shardPatterns(bpa)
+	done, err := a.Checkpoint.StartPattern(bpai)
+	if err != nil || len(done) == 0 || len(bpa) == 0 {
+		return ret, ok, err
+	}
+	if ret == nil {
+		ret = make([]bool, len(bpa[0].Pattern))
+		for pi := range ret {
+			ret[pi] = true
+		}
+	}
+	for _, pi := range done {
+		if pi < len(ret) {
+			ret[pi] = false
+		}
+	}
+	ok = false
+	for pi := range ret {
+		ok = ok || ret[pi]
+	}
+	return ret, ok, nil
+}
+
+// shardPatterns : --shardのときに，最初の基本句のパターンごとに，このシャードで探索するかどうか
+// 文パターンと最初の基本句のパターンの組を探索の単位として，見つけた順に通し番号をつける．
+// シャードで分けないときはnilを返す．探索するパターンがひとつもなければfalseを返す
+func (a *Arrange) shardPatterns(bpa []BasicPhrase) ([]bool, bool) {
+	if a.Options.ShardCount <= 1 || len(bpa) == 0 {
+		return nil, true
+	}
+	ret := make([]bool, len(bpa[0].Pattern))
+	found := false
+	for pi := range ret {
+		ret[pi] = a.Options.InShard(a.shardUnit + pi)
+		found = found || ret[pi]
+	}
+	a.shardUnit += len(ret)
+	return ret, found
+}
+
 func (a *Arrange) Output() error {
 	ocount, err := a.Writer.Output(
 		a.Keyword, a.Surfaces, a.Results, a.WipedLength, a.Width)
@@ -375,7 +509,13 @@ func (a *Arrange) Output() error {
 		}
 		total += count[i]
 	}
-	fmt.Printf("%v results found, %v\n", total, count)
+	if a.Options.Silent == false {
+		if a.Truncated {
+			fmt.Printf("%v results found, %v (truncated)\n", total, count)
+		} else {
+			fmt.Printf("%v results found, %v\n", total, count)
+		}
+	}
 	if a.Options.Verbose || a.Options.Verbosely {
 		log.Debug(MemoryInfo())
 	}
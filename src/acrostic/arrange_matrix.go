@@ -1,15 +1,15 @@
 package acrostic
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"runtime"
-	"strconv"
 	"sync"
+	"sync/atomic"
 
 	"github.com/noyuno/lgo/algo"
 	"github.com/noyuno/lgo/runes"
-	log "github.com/sirupsen/logrus"
 )
 
 type ArrangeMatrix struct {
@@ -42,6 +42,19 @@ type ArrangeMatrix struct {
 	KeywordEnd []int
 	// 縦読み列が設定されているかどうか
 	IsTargeting bool
+	// キーワードの先頭の文字を置く列(-1ならばどこでもよい)
+	PinnedColumn int
+	// --multiで同時に隠すキーワードの探索状態(空ならばKeywordだけを探す)
+	Hidden []ArrangeHidden
+	// 解に至らなかった探索状態の表(nilならば使わない)
+	Memo *ArrangeMemo
+	// 探索を分けて並列に行うワーカープール(nilならば並列処理しない)
+	Scheduler *ArrangeScheduler
+	// 最初の基本句のパターンごとに，探索するかどうか(nilならばすべて探索する)
+	// --shardで他のシャードに配ったパターンと，--resumeで探索を終えていたパターンはfalse
+	TopPatterns []bool
+	// 最初の基本句のパターンの探索を終えるたびに結果を書き出して覚えておく(nilならば使わない)
+	Checkpoint *Checkpoint
 	// うまくいったやつ
 	MatrixResult []ArrangeMatrixResult
 	// CPUの数
@@ -55,6 +68,8 @@ type ArrangeMatrix struct {
 	Writer *ArrangeWriter
 	// WriterのMutex
 	WriterMutex sync.RWMutex
+	// MatrixResult, WipedLengthのMutex(並列処理のとき，同じ行列の子を複数のgoroutineが探索する)
+	resultMutex sync.Mutex
 
 	// 並列処理を無効化
 	DisableParallel bool
@@ -89,6 +104,14 @@ type ArrangeMatrixResult struct {
 	//Surface     []rune
 	PatternStack []int
 	BranchStack  []int
+	// Score : 自然さのスコア(ScorePattern)
+	Score float64
+	// Step : キーワードが1行ごとにずれる列数(Options.DirectionStep)
+	Step int
+	// Hidden : --multiのときの隠れたキーワード(先頭はKeywordEndと同じ)
+	Hidden []HiddenKeyword
+	// Ruby : かなにした部分と元の表記(RubyPattern)
+	Ruby []Ruby
 }
 
 func NewArrangeMatrix(o *Options,
@@ -148,9 +171,79 @@ func NewArrangeMatrix(o *Options,
 	ret.PatternStack = stack
 	ret.BranchStack = bstack
 	ret.Width = width
+	ret.PinnedColumn = o.PinnedColumn(width)
 	return ret, nil
 }
 
+// nextKeywordColumn : keywordendの次の行でキーワードの文字が来るべき列
+// 縦読みならば同じ列，斜め読みならば1列ずれる
+func (m *ArrangeMatrix) nextKeywordColumn(keywordend []int) int {
+	return keywordend[1] + m.Options.DirectionStep()
+}
+
+// keywordColumnAt : 終端がkeywordendのキーワードについて，行rowの文字がある列
+func keywordColumnAt(keywordend []int, step int, row int) int {
+	return keywordend[1] - step*(keywordend[0]-row)
+}
+
+// keywordFits : キーワードのki番目の文字を列xに置いたとき，最後の文字まで行列の幅に収まるかどうか
+// 縦読みならばつねにtrue
+func (m *ArrangeMatrix) keywordFits(ki int, x int) bool {
+	last := x + m.Options.DirectionStep()*(len(m.Keyword)-1-ki)
+	return 0 <= last && last < m.Width
+}
+
+// keywordReachable : 残りの基本句の最大の長さ(PatternMaxLength)で，キーワードの最後の文字まで届くかどうか
+// キーワードの列がまだ決まっていないときや，残りの基本句で改行するときはtrue
+func (m *ArrangeMatrix) keywordReachable() bool {
+	if m.FinishedSearch {
+		return true
+	}
+	step := m.Options.DirectionStep()
+	row, col := 0, 0
+	if m.IsTargeting {
+		row = m.KeywordEnd[0] + 1
+		col = m.nextKeywordColumn(m.KeywordEnd)
+	} else if m.PinnedColumn >= 0 {
+		row = m.MatrixIndex[0]
+		col = m.PinnedColumn + step*m.KeywordIndex
+		if m.MatrixIndex[1] > col {
+			// この行では固定した列を過ぎている
+			row++
+		}
+		if m.Options.MatchLength && row > m.KeywordIndex {
+			return false
+		}
+	} else {
+		return true
+	}
+	maxremain, newline := m.remainLength()
+	if newline {
+		return true
+	}
+	return m.cellsNeeded(row, col, len(m.Keyword)-1-m.KeywordIndex) <= maxremain
+}
+
+// remainLength : 残りの基本句の最大の長さ(PatternMaxLength)の合計と，残りの基本句で改行するかどうか
+func (m *ArrangeMatrix) remainLength() (int, bool) {
+	maxremain := 0
+	newline := false
+	for i := m.BasicPhraseIndex; i < len(m.BasicPhrases); i++ {
+		if m.BasicPhrases[i].NewLine && (i != m.BasicPhraseIndex || m.MatrixIndex[1] != 0) {
+			newline = true
+		}
+		maxremain += m.BasicPhrases[i].PatternMaxLength
+	}
+	return maxremain, newline
+}
+
+// cellsNeeded : 今の位置から，(row, col)に置くキーワードの文字とその後のremain文字までのマスの数
+// 改行しなければ，これらのマスをすべて埋める必要がある
+func (m *ArrangeMatrix) cellsNeeded(row int, col int, remain int) int {
+	step := m.Options.DirectionStep()
+	return (row+remain-m.MatrixIndex[0])*m.Width + col + step*remain - m.MatrixIndex[1] + 1
+}
+
 func (m *ArrangeMatrix) expectedLine() int {
 	maxremain := 0
 	lflen := 0
@@ -165,6 +258,7 @@ func (m *ArrangeMatrix) expectedLine() int {
 }
 
 // SearchContext : 検索
+// ctx: 終了したら，それ以上探索せずに戻る（見つかった結果はMatrixResultに残る）
 // indent: インデント
 // k: キーワード
 // pi: パターンの番号
@@ -172,6 +266,7 @@ func (m *ArrangeMatrix) expectedLine() int {
 // return int: 刈った数(成功した数ではない．)
 // return error: エラー
 func (m *ArrangeMatrix) SearchContext(
+	ctx context.Context,
 	indent string,
 	k []rune,
 	pi int,
@@ -229,6 +324,11 @@ func (m *ArrangeMatrix) SearchContext(
 	//	log.Debugf("under: %v", under)
 	//}
 
+	// 打ち切り
+	if ctx.Err() != nil {
+		return 0, nil
+	}
+
 	// 枝刈り：残りキーワードの文字数が残り行数よりも大きければ終了
 	// 切り上げ
 	expline := m.expectedLine()
@@ -238,6 +338,11 @@ func (m *ArrangeMatrix) SearchContext(
 		return 0, nil
 	}
 
+	// 枝刈り：キーワードの列(固定した列または縦読み列)まで残りの文字数で届かなければ終了
+	if m.keywordReachable() == false {
+		return 0, nil
+	}
+
 	// 枝刈り：MatchLengthで1行目にキーワードがなければ終了
 	// MatrixIndexがKeywordEndの列を超えていれば終了
 	if m.Options.MatchLength {
@@ -245,7 +350,10 @@ func (m *ArrangeMatrix) SearchContext(
 			//log.Debugf(indent + "pruning MatchLength: not appearing keyword at line 0")
 			return 0, nil
 		}
-		if m.MatrixIndex[0] > m.KeywordEnd[0] && m.MatrixIndex[1] >= m.KeywordEnd[1] {
+		// キーワードを探している途中ならば，ちょうどキーワード列から書き始めるのはよい
+		next := m.nextKeywordColumn(m.KeywordEnd)
+		if m.MatrixIndex[0] > m.KeywordEnd[0] &&
+			(m.MatrixIndex[1] > next || m.MatrixIndex[1] == next && m.IsTargeting == false) {
 			//log.Debugf(indent + "pruning MatchLength: MatrixIndex exceeded keyword column")
 			return 0, nil
 		}
@@ -266,7 +374,7 @@ func (m *ArrangeMatrix) SearchContext(
 	for r != -1 {
 		//log.Debugf(indent+"found %v in pattern[%v] = %v at %v",
 		//	string(k), pi, string(p), r)
-		// 文字列中に検索文字が見つかったとしても，同じ列かつ次の行でなければならない
+		// 文字列中に検索文字が見つかったとしても，次の行のキーワード列(縦読みならば同じ列)でなければならない
 		y := m.MatrixIndex[0]
 		//x := m.KeywordEnd[0]
 		x := m.MatrixIndex[1] + r
@@ -297,18 +405,28 @@ func (m *ArrangeMatrix) SearchContext(
 		}
 		// 列数不一致ならはじく
 		if m.IsTargeting {
-			if m.KeywordEnd[1] != x {
+			if m.nextKeywordColumn(m.KeywordEnd) != x {
 				// 列不一致
 				//log.Debugf(indent+"column mismatch, KeywordEnd[0]=%v, x=%v",
 				//	m.KeywordEnd[0], x)
 				break
 			}
 		}
+		// 斜め読みで，残りのキーワードが行列の幅に収まらなければ次の位置を探す
+		if m.keywordFits(m.KeywordIndex, x) == false {
+			r = runes.Index(p, k, r+1)
+			continue
+		}
+		// 列を固定しているときは，その列でなければ次の位置を探す
+		if m.PinnedColumn >= 0 && m.PinnedColumn+m.Options.DirectionStep()*m.KeywordIndex != x {
+			r = runes.Index(p, k, r+1)
+			continue
+		}
 		//log.Debugf(indent+"y=%v, x=%v, IsTargeting=%v", y, x, m.IsTargeting)
 		// copy matrix and append found phrase to this matrix
 		//mat := CopyMatrix(m.Matrix)
 		mat := make([][]rune, 1)
-		mat[0] = make([]rune, m.Width)
+		mat[0] = m.newRow()
 		matpos := []int{0, 0}
 		matstart := []int{0, 0}
 		newline := false
@@ -334,13 +452,30 @@ func (m *ArrangeMatrix) SearchContext(
 		keywordindex := m.KeywordIndex
 		matline := matpos[0]
 		//log.Debugf("keywordend: %v, keywordindex: %v", keywordend, keywordindex)
+		// hung : この基本句で文字をぶら下げたかどうか
+		hung := false
 		for i := range p {
 			//log.Debugf("B [%v %v] %v '%v'", matpos[0], matpos[1], i, string(p[i]))
-			if matpos[1] == x && matpos[0] > y {
+			if m.Options.Kinsoku {
+				h, ok := m.placeKinsoku(mat, matline, p, i, matpos, newline)
+				if ok == false || (h && i == r) {
+					return 0, nil
+				}
+				if h {
+					hung = true
+					continue
+				}
+				if hung && i == r && (matpos[0] != y || matpos[1] != x) {
+					// ぶら下げたので，キーワードの文字の位置がずれた
+					return 0, nil
+				}
+			}
+			if matpos[0] == keywordend[0]+1 && matpos[1] == m.nextKeywordColumn(keywordend) {
 				// 折り返し
 				//log.Debugf("折り返し")
 				if len(m.Keyword) > keywordindex+1 {
 					keywordend[0]++
+					keywordend[1] = matpos[1]
 					keywordindex++
 					//log.Debugf("B: keywordend: %v, keywordindex: %v", keywordend, keywordindex)
 					if m.Keyword[keywordindex] != p[i] {
@@ -352,7 +487,7 @@ func (m *ArrangeMatrix) SearchContext(
 				} else {
 					if m.Options.MatchLength {
 						//PrintMatrix(mat, matpos)
-						log.Fatal("範囲外")
+						return 0, fmt.Errorf("%w: keyword index %v", ErrOutOfRange, keywordindex+1)
 					}
 				}
 			}
@@ -360,11 +495,14 @@ func (m *ArrangeMatrix) SearchContext(
 			flag := 0
 			matpos, flag = algo.SliceAdderR(matpos, m.MatrixIndexMax, len(matpos))
 			if flag == 3 {
-				log.Fatal("array filled up")
+				return 0, fmt.Errorf("%w: array filled up", ErrOutOfRange)
 			} else if flag == 1 && len(p) > i+1 {
-				mat = append(mat, make([]rune, m.Width))
+				mat = append(mat, m.newRow())
 			}
 		}
+		if hung {
+			mat = trimHanging(mat, matpos, matline)
+		}
 		//PrintMatrix(mat, matpos)
 		//TypeToContinue()
 		//log.Debugf("matpos=%v", matpos)
@@ -380,7 +518,7 @@ func (m *ArrangeMatrix) SearchContext(
 
 		// BUG: keywordend=[0 0]なのにkeywordindex=1なのはおかしくて，0であるべきだ．
 		if keywordend[0] < keywordindex {
-			log.Fatalf("Oops! keywordend=%v, but keywordindex=%v", keywordend, keywordindex)
+			return 0, fmt.Errorf("%w: keywordend=%v, but keywordindex=%v", ErrOutOfRange, keywordend, keywordindex)
 		}
 		if m.BasicPhraseIndex+1 >= len(m.BasicPhrases) {
 			// BasicPhrase探索終了
@@ -388,7 +526,7 @@ func (m *ArrangeMatrix) SearchContext(
 				accept := false
 				// 行数不一致なら不一致
 				if m.Options.MatchLength {
-					if matpos[1] >= keywordend[1] {
+					if matpos[1] >= m.nextKeywordColumn(keywordend) {
 						// 文末端より左側
 						if matpos[0] == keywordend[0] {
 							accept = true
@@ -419,8 +557,8 @@ func (m *ArrangeMatrix) SearchContext(
 					//for i := range m.BasicPhrases {
 					//	surface = append(surface, m.BasicPhrases[i].Surface...)
 					//}
-					m.MatrixResult = append(m.MatrixResult, m.makeResult(
-						mat, matpos, newline,
+					m.addResults(0, m.makeResult(
+						mat, matpos, newline, keywordend,
 						append(m.PatternStack, pi),
 						append(m.BranchStack, 0)))
 					//m.MatrixResult = append(m.MatrixResult, ArrangeMatrixResult{
@@ -464,16 +602,17 @@ func (m *ArrangeMatrix) SearchContext(
 				//	m.KeywordIndex, finished, keywordend, string(m.Keyword))
 				am.KeywordEnd = keywordend
 				am.DisableParallel = m.DisableParallel
+				am.Memo = m.Memo
+				am.Scheduler = m.Scheduler
 				//am.SearchKeyword = false
 				am.FinishedSearch = true
 				am.Parent = m
 				am.NewLine = newline
-				err = am.Search(m.PatternStack, foundn)
+				err = am.Search(ctx, m.PatternStack, foundn)
 				if err != nil {
 					return 0, err
 				}
-				m.MatrixResult = append(m.MatrixResult, am.MatrixResult...)
-				m.WipedLength += am.WipedLength
+				m.addResults(am.WipedLength, am.MatrixResult...)
 			} else {
 				// キーワードの探索およびBPの探索が終わっていない
 				//log.Debugf(indent+"A: new instance, KeywordIndex=%v, keywordend=%v, stack=%v",
@@ -498,19 +637,20 @@ func (m *ArrangeMatrix) SearchContext(
 				am.IsTargeting = true
 				am.KeywordEnd = keywordend //[]int{matpos[0], keywordend[1]}
 				am.DisableParallel = m.DisableParallel
+				am.Memo = m.Memo
+				am.Scheduler = m.Scheduler
 				am.Parent = m
 				am.NewLine = newline
 				//log.Debugf(indent+"m=%v, am=%v", m.BasicPhraseIndex, am.BasicPhraseIndex)
-				err = am.Search(m.PatternStack, foundn)
+				err = am.Search(ctx, m.PatternStack, foundn)
 				if err != nil {
 					return 0, err
 				}
 				//log.Debugf(indent+"m=%v, am=%v", m.BasicPhraseIndex, am.BasicPhraseIndex)
-				m.MatrixResult = append(m.MatrixResult, am.MatrixResult...)
-				m.WipedLength += am.WipedLength
+				m.addResults(am.WipedLength, am.MatrixResult...)
 				//log.Debugf(indent + "Search end")
 			}
-			if m.Options.One && len(m.MatrixResult) > 0 {
+			if m.Options.One && m.resultLen() > 0 {
 				return 0, nil
 			}
 		}
@@ -534,7 +674,7 @@ func (m *ArrangeMatrix) SearchContext(
 	//	len(p), m.MatrixIndex)
 	//mat := CopyMatrix(m.Matrix)
 	mat := make([][]rune, 1)
-	mat[0] = make([]rune, m.Width)
+	mat[0] = m.newRow()
 	matpos := []int{0, 0}
 	matstart := []int{0, 0}
 	newline := false
@@ -551,23 +691,37 @@ func (m *ArrangeMatrix) SearchContext(
 			matpos[1] = 0
 			//mat = append(mat, make([]rune, m.Width))
 		} else {
-			log.Fatalf(indent+"array filled up (lf), MatrixIndexMax: %v, matpos[0]+1: %v",
-				m.MatrixIndexMax, matpos[0]+1)
+			return 0, fmt.Errorf("%w: array filled up (lf), MatrixIndexMax: %v, matpos[0]+1: %v",
+				ErrOutOfRange, m.MatrixIndexMax, matpos[0]+1)
 		}
 	}
 	matline := matpos[0]
 	//log.Debugf(indent+"B: append '%v' (%v char) to mat", string(p), len(p))
+	hung := false
 	for i := range p {
 		//log.Debugf("B [%v %v] %v '%v'", matpos[0], matpos[1], i, string(p[i]))
+		if m.Options.Kinsoku {
+			h, ok := m.placeKinsoku(mat, matline, p, i, matpos, newline)
+			if ok == false {
+				return 0, nil
+			}
+			if h {
+				hung = true
+				continue
+			}
+		}
 		mat[matpos[0]-matline][matpos[1]] = p[i]
 		flag := 0
 		matpos, flag = algo.SliceAdderR(matpos, m.MatrixIndexMax, len(matpos))
 		if flag == 3 {
-			log.Fatal(indent + "array filled up")
+			return 0, fmt.Errorf("%w: array filled up", ErrOutOfRange)
 		} else if flag == 1 && len(p) > i+1 {
-			mat = append(mat, make([]rune, m.Width))
+			mat = append(mat, m.newRow())
 		}
 	}
+	if hung {
+		mat = trimHanging(mat, matpos, matline)
+	}
 	//PrintMatrix(mat, matpos)
 	//if len(k) == m.KeywordIndex+1 {
 	//	m.FinishedSearch = true
@@ -578,7 +732,7 @@ func (m *ArrangeMatrix) SearchContext(
 		accept := false
 		// 行数不一致なら不一致
 		if m.Options.MatchLength {
-			if matpos[1] >= m.KeywordEnd[1] {
+			if matpos[1] >= m.nextKeywordColumn(m.KeywordEnd) {
 				// 文末端より左側
 				if matpos[0] == m.KeywordEnd[0] {
 					accept = true
@@ -606,8 +760,8 @@ func (m *ArrangeMatrix) SearchContext(
 			//for i := range m.BasicPhrases {
 			//	surface = append(surface, m.BasicPhrases[i].Surface...)
 			//}
-			m.MatrixResult = append(m.MatrixResult,
-				m.makeResult(mat, matpos, newline,
+			m.addResults(0,
+				m.makeResult(mat, matpos, newline, m.KeywordEnd,
 					append(m.PatternStack, pi),
 					append(m.BranchStack, 1)))
 			//m.MatrixResult = append(m.MatrixResult, ArrangeMatrixResult{
@@ -648,36 +802,60 @@ func (m *ArrangeMatrix) SearchContext(
 		am.KeywordEnd = m.KeywordEnd
 		am.IsTargeting = m.IsTargeting
 		am.DisableParallel = m.DisableParallel
+		am.Memo = m.Memo
+		am.Scheduler = m.Scheduler
 		am.TextIndex = m.TextIndex
 		am.Parent = m
 		am.NewLine = newline
 		//log.Debugf(indent+"m=%v, am=%v", m.BasicPhraseIndex, am.BasicPhraseIndex)
-		am.Search(m.PatternStack, 0)
+		if err := am.Search(ctx, m.PatternStack, 0); err != nil {
+			return 0, err
+		}
 		//log.Debugf(indent+"m=%v, am=%v", m.BasicPhraseIndex, am.BasicPhraseIndex)
-		m.MatrixResult = append(m.MatrixResult, am.MatrixResult...)
-		m.WipedLength += am.WipedLength
+		m.addResults(am.WipedLength, am.MatrixResult...)
 	}
 	//m.SkipLengthMutex.Lock()
 	//m.SkipLength[len(p)] = true
 	//m.SkipLengthMutex.Unlock()
 
-	// m.MatrixResult がいっぱいだったらwipe outする
-	m.WriterMutex.Lock()
+	return 0, m.wipeOutIfFull()
+}
+
+// wipeOutIfFull : m.MatrixResult がいっぱいだったらwipe outする
+func (m *ArrangeMatrix) wipeOutIfFull() error {
+	m.resultMutex.Lock()
+	defer m.resultMutex.Unlock()
 	if m.Options.WipeOut && m.Options.WipeOutLength <= len(m.MatrixResult) {
-		length := len(m.MatrixResult)
-		err := m.WipeOut()
-		if err != nil {
-			m.WriterMutex.Unlock()
-			return 0, err
-		}
-		m.WipedLength += length
+		return m.wipeOutAll()
 	}
-	m.WriterMutex.Unlock()
-	return 0, nil
+	return nil
 }
 
+// flush : m.MatrixResult があればwipe outする
+func (m *ArrangeMatrix) flush() error {
+	m.resultMutex.Lock()
+	defer m.resultMutex.Unlock()
+	if len(m.MatrixResult) > 0 {
+		return m.wipeOutAll()
+	}
+	return nil
+}
+
+// wipeOutAll : m.MatrixResult をwipe outして，WipedLengthに数える(resultMutexをロックして呼ぶ)
+func (m *ArrangeMatrix) wipeOutAll() error {
+	length := len(m.MatrixResult)
+	err := m.WipeOut()
+	if err != nil {
+		return err
+	}
+	m.WipedLength += length
+	return nil
+}
+
+// makeResult : 親の行列をつなげて結果を作る
+// keywordend: 縦読み列の終端（この基本句でキーワードが終わったときは，この基本句での位置）
 func (m *ArrangeMatrix) makeResult(
-	in [][]rune, matpos []int, newline bool,
+	in [][]rune, matpos []int, newline bool, keywordend []int,
 	stack []int, bstack []int) ArrangeMatrixResult {
 	//log.Debugf("makeResult")
 	parents := make([]*ArrangeMatrix, 0)
@@ -699,7 +877,7 @@ func (m *ArrangeMatrix) makeResult(
 				}
 			} else {
 				//log.Debugf("new line")
-				mat = append(mat, make([]rune, m.Width))
+				mat = append(mat, m.newRow())
 				copy(mat[len(mat)-1], parents[p].Matrix[n])
 			}
 			//log.Debugf("%v: %v", len(mat)-1, string(mat[len(mat)-1]))
@@ -714,7 +892,7 @@ func (m *ArrangeMatrix) makeResult(
 			}
 		} else {
 			//log.Debugf("in new line")
-			mat = append(mat, make([]rune, m.Width))
+			mat = append(mat, m.newRow())
 			copy(mat[len(mat)-1], in[n])
 		}
 	}
@@ -724,9 +902,12 @@ func (m *ArrangeMatrix) makeResult(
 	return ArrangeMatrixResult{
 		Matrix:       mat,
 		MatrixIndex:  matpos,
-		KeywordEnd:   []int{m.KeywordEnd[0], m.KeywordEnd[1]},
+		KeywordEnd:   []int{keywordend[0], keywordend[1]},
 		PatternStack: stack,
 		BranchStack:  bstack,
+		Score:        ScorePattern(m.BasicPhrases, stack, DefaultScoreWeights),
+		Step:         m.Options.DirectionStep(),
+		Ruby:         RubyPattern(m.BasicPhrases, stack),
 	}
 }
 
@@ -736,6 +917,10 @@ func (m *ArrangeMatrix) Skip() error {
 }
 
 func (m *ArrangeMatrix) CheckAfterKeyword() bool {
+	// 枝刈り：斜め読みで，残りキーワードの列が行列の幅からはみ出すならば終了
+	if m.IsTargeting && m.keywordFits(m.KeywordIndex, m.nextKeywordColumn(m.KeywordEnd)) == false {
+		return false
+	}
 	// 枝刈り：残りの場所に残りキーワードが順番通りに来なければ終了
 	found := true
 	// keywordとBasicPhrase iの関連
@@ -816,25 +1001,24 @@ func (m *ArrangeMatrix) CheckAfterKeyword() bool {
 	//}
 }
 
-func (m *ArrangeMatrix) Search(oldstack []int, foundnum int) error {
+func (m *ArrangeMatrix) Search(ctx context.Context, oldstack []int, foundnum int) error {
 	var err error
 	//if len(m.BasicPhrases)-m.BasicPhraseIndex > 10 {
 	//	log.Debugf("Search: %v", m.PatternStack)
 	//}
 	m.Progress.Set(m.ProgressID, m.PatternStack)
+	m.Progress.AddNode()
 	if m.FinishedSearch == false && m.CheckAfterKeyword() == false {
 		//log.Debugf("not found after this")
 		return nil
 	}
-	if m.Options.Parallel &&
-		m.DisableParallel == false &&
-		len(m.BasicPhrases[m.BasicPhraseIndex].Pattern) >= m.NumCPU {
-		m.DisableParallel = true
-		log.Debugf("parallel at %v %v items", m.BasicPhraseIndex, len(m.BasicPhrases[m.BasicPhraseIndex].Pattern))
-		_, err = m.SearchParallel(oldstack, foundnum)
-	} else {
-		_, err = m.SearchNormal(oldstack, foundnum)
+	// 別の経路ですでに解がないとわかった状態ならば終了
+	state := m.state()
+	if m.Memo.Dead(state) {
+		return nil
 	}
+	defer m.markIfDead(ctx, state)
+	_, err = m.SearchNormal(ctx, oldstack, foundnum)
 	return err
 }
 
@@ -857,10 +1041,11 @@ func (m *ArrangeMatrix) getPattern() chan ArrangeMatrixPattern {
 			le := map[int]bool{}
 			// 差
 			diff := 0
+			next := m.nextKeywordColumn(m.KeywordEnd)
 			if b.NewLine {
-				diff = m.KeywordEnd[1]
+				diff = next
 			} else {
-				diff = m.KeywordEnd[1] - m.MatrixIndex[1]
+				diff = next - m.MatrixIndex[1]
 				if diff < 0 {
 					// 折り返し
 					diff += m.Width
@@ -907,58 +1092,119 @@ func (m *ArrangeMatrix) getPattern() chan ArrangeMatrixPattern {
 	return p
 }
 
-func (m *ArrangeMatrix) SearchNormal(oldstack []int, foundnum int) (int, error) {
-	var err error
+// SearchNormal : パターンを順に探索する
+// Schedulerに空いているワーカーがあれば，パターンひとつ分の探索をワーカーに渡す(どの深さでもよい)
+func (m *ArrangeMatrix) SearchNormal(ctx context.Context, oldstack []int, foundnum int) (int, error) {
 	indent := Indent(m.BasicPhraseIndex)
 	k := m.Keyword[m.KeywordIndex : m.KeywordIndex+1]
 	//b := m.BasicPhrases[m.BasicPhraseIndex]
 	//log.Debugf(indent+"search %v into %v", string(k), string(b.Surface))
 
-	for p := range m.getPattern() {
-		//log.Debugf("BasicPhrases[%v].Pattern[%v]%v", m.BasicPhraseIndex, pi, string(p))
-		_, err = m.SearchContext(indent, k, p.Index, p.Text, m.ProgressID, oldstack, foundnum)
+	var err error
+	var wg sync.WaitGroup
+	// failed : どれかのパターンでエラーになったら，残りのパターンは探索しない
+	var failed int32
+	// searchErr : 最初に起きたエラー(ワーカーからも書き込むのでmuで守る)
+	var searchErr error
+	var mu sync.Mutex
+	search := func(p ArrangeMatrixPattern, progressid int) {
+		_, err := m.SearchContext(ctx, indent, k, p.Index, p.Text, progressid, oldstack, foundnum)
 		if err != nil {
-			log.Warn(err.Error())
+			mu.Lock()
+			if searchErr == nil {
+				searchErr = err
+			}
+			mu.Unlock()
+			atomic.StoreInt32(&failed, 1)
+		}
+	}
+	patterns := m.getPattern()
+	for p := range patterns {
+		if ctx.Err() != nil || atomic.LoadInt32(&failed) != 0 {
 			break
 		}
-		if m.Options.One && len(m.MatrixResult) > 0 {
+		//log.Debugf("BasicPhrases[%v].Pattern[%v]%v", m.BasicPhraseIndex, pi, string(p))
+		if m.searchTop(p.Index) == false {
+			continue
+		}
+		if m.splittable() {
+			p := p
+			if m.Scheduler.Go(&wg, func(progressid int) { search(p, progressid) }) {
+				continue
+			}
+		}
+		search(p, m.ProgressID)
+		if m.Checkpoint != nil && atomic.LoadInt32(&failed) == 0 {
+			err = m.finishTop(ctx, p.Index)
+			if err != nil {
+				break
+			}
+		}
+		if m.Options.One && m.resultLen()+m.wipedLen() > 0 {
 			break
 		}
 		//m.Progress.Set(pi, m.PatternStack, m.BasicPhraseIndex)
 	}
+	// getPatternのgoroutineを終わらせる
+	for range patterns {
+	}
+	wg.Wait()
 	ret := 0
+	if searchErr != nil {
+		return ret, searchErr
+	}
+	return ret, err
+}
 
-	return ret, nil
+// splittable : この行列のパターンの探索をワーカーに渡してよいかどうか
+// 最後の基本句は探索がすぐに終わるので，渡さずに自分で探索する．
+// Checkpointがあるときは，パターンの探索を終えたことを順に覚えるため，自分で探索する
+func (m *ArrangeMatrix) splittable() bool {
+	return m.DisableParallel == false && m.Checkpoint == nil &&
+		m.BasicPhraseIndex+1 < len(m.BasicPhrases)
 }
 
-func (m *ArrangeMatrix) SearchParallel(oldstack []int, foundnum int) (int, error) {
-	indent := Indent(m.BasicPhraseIndex)
-	k := m.Keyword[m.KeywordIndex : m.KeywordIndex+1]
-	b := m.BasicPhrases[m.BasicPhraseIndex]
-	//log.Debugf(indent+"search parallel %v into %v", string(k), string(b.Surface))
-	var wg sync.WaitGroup
-	semaphore := make(chan int, m.NumCPU)
-	for pi, p := range b.Pattern {
-		wg.Add(1)
-		go func(pi int, p []rune) {
-			defer wg.Done()
-			semaphore <- 1
-			progressid := m.Progress.Add("parallel" + strconv.Itoa(pi))
-			_, err := m.SearchContext(indent, k, pi, p, progressid, oldstack, foundnum)
-			m.Progress.Remove(progressid)
-			if err != nil {
-				log.Warn(err.Error())
-			}
-			//progress.Set(pi, m.PatternStack, m.BasicPhraseIndex)
-			<-semaphore
-		}(pi, p)
-		if m.Options.One && len(m.MatrixResult) > 0 {
-			break
-		}
+// finishTop : 最初の基本句のパターンpiの探索を終えたら，結果を書き出してCheckpointに覚えさせる
+// 打ち切られたときは，再開したときにもう一度見つかるので，結果を捨てる
+func (m *ArrangeMatrix) finishTop(ctx context.Context, pi int) error {
+	if ctx.Err() != nil {
+		m.resultMutex.Lock()
+		m.MatrixResult = m.MatrixResult[:0]
+		m.resultMutex.Unlock()
+		return nil
 	}
-	wg.Wait()
-	ret := 0
-	return ret, nil
+	err := m.flush()
+	if err != nil {
+		return err
+	}
+	return m.Checkpoint.FinishTop(pi)
+}
+
+// searchTop : パターンpiを探索するかどうか(最初の基本句のほかはすべて探索する)
+func (m *ArrangeMatrix) searchTop(pi int) bool {
+	return m.BasicPhraseIndex != 0 || m.TopPatterns == nil || m.TopPatterns[pi]
+}
+
+// addResults : 結果とwipe outした数を加える．並列に呼んでもよい
+func (m *ArrangeMatrix) addResults(wiped int, r ...ArrangeMatrixResult) {
+	m.resultMutex.Lock()
+	m.MatrixResult = append(m.MatrixResult, r...)
+	m.WipedLength += wiped
+	m.resultMutex.Unlock()
+}
+
+// resultLen : 結果の数
+func (m *ArrangeMatrix) resultLen() int {
+	m.resultMutex.Lock()
+	defer m.resultMutex.Unlock()
+	return len(m.MatrixResult)
+}
+
+// wipedLen : wipe outした数
+func (m *ArrangeMatrix) wipedLen() int {
+	m.resultMutex.Lock()
+	defer m.resultMutex.Unlock()
+	return m.WipedLength
 }
 
 func CopyMatrix(in [][]rune) [][]rune {
@@ -0,0 +1,115 @@
+package acrostic
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// arrangeState : ArrangeMatrixの探索状態．これが同じならば，その先の探索の結果も同じになる
+type arrangeState struct {
+	bpindex   int
+	row       int
+	col       int
+	kindex    int
+	targeting bool
+	finished  bool
+	endrow    int
+	endcol    int
+	// hidden : --multiのときの各キーワードの状態
+	hidden string
+}
+
+// ArrangeMemo : 解に至らなかった探索状態を覚えておく表
+// 別の経路で同じ状態に来たときは，その先の探索を省略する．
+// 文パターンごとに作り，並列処理の各goroutineで共有する
+type ArrangeMemo struct {
+	// Size : 覚えておく状態の最大数
+	Size int
+	// Hits : 探索を省略した回数
+	Hits int64
+
+	mutex sync.RWMutex
+	dead  map[arrangeState]bool
+}
+
+// NewArrangeMemo : constructor
+// size: 覚えておく状態の最大数(0以下ならばnilを返し，何も覚えない)
+func NewArrangeMemo(size int) *ArrangeMemo {
+	if size <= 0 {
+		return nil
+	}
+	ret := new(ArrangeMemo)
+	ret.Size = size
+	ret.dead = make(map[arrangeState]bool)
+	return ret
+}
+
+// Dead : 解に至らないとわかっている状態かどうか
+func (t *ArrangeMemo) Dead(s arrangeState) bool {
+	if t == nil {
+		return false
+	}
+	t.mutex.RLock()
+	ok := t.dead[s]
+	t.mutex.RUnlock()
+	if ok {
+		atomic.AddInt64(&t.Hits, 1)
+	}
+	return ok
+}
+
+// MarkDead : 解に至らなかった状態を覚える．Sizeを超えたら覚えない
+func (t *ArrangeMemo) MarkDead(s arrangeState) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	if len(t.dead) < t.Size {
+		t.dead[s] = true
+	}
+	t.mutex.Unlock()
+}
+
+// Len : 覚えている状態の数
+func (t *ArrangeMemo) Len() int {
+	if t == nil {
+		return 0
+	}
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return len(t.dead)
+}
+
+// state : 探索状態
+func (m *ArrangeMatrix) state() arrangeState {
+	ret := arrangeState{
+		bpindex:   m.BasicPhraseIndex,
+		row:       m.MatrixIndex[0],
+		col:       m.MatrixIndex[1],
+		kindex:    m.KeywordIndex,
+		targeting: m.IsTargeting,
+		finished:  m.FinishedSearch,
+		endrow:    m.KeywordEnd[0],
+		endcol:    m.KeywordEnd[1],
+	}
+	if len(m.Hidden) > 0 {
+		hidden := make([]string, len(m.Hidden))
+		for i, h := range m.Hidden {
+			hidden[i] = strconv.Itoa(h.Index) + ":" + strconv.Itoa(h.End[0]) + ":" + strconv.Itoa(h.End[1])
+		}
+		ret.hidden = strings.Join(hidden, ",")
+	}
+	return ret
+}
+
+// markIfDead : 探索を最後まで行って結果がひとつもなければ，その状態を覚える
+// 打ち切られたときは，探索していない部分があるので覚えない
+func (m *ArrangeMatrix) markIfDead(ctx context.Context, s arrangeState) {
+	if ctx.Err() != nil || m.resultLen() > 0 || m.wipedLen() > 0 {
+		return
+	}
+	m.Memo.MarkDead(s)
+}
@@ -0,0 +1,57 @@
+package acrostic
+
+import (
+	"context"
+	"testing"
+)
+
+func tSearchMemo(t *testing.T, memo *ArrangeMemo, middle string) *ArrangeMatrix {
+	o := &Options{Height: 6, Silent: true}
+	keyword := []rune("みか")
+	bpa := tNewBasicPhrases("あい", "み", middle, "か")
+	// 長さが同じ2つのパターンは，どちらを選んでも同じ状態になる
+	bpa[0].Pattern = [][]rune{[]rune("あい"), []rune("うえ")}
+	for i := range bpa {
+		if i != 0 {
+			bpa[i].Pattern = [][]rune{bpa[i].Surface}
+		}
+		bpa[i].UpdatePatternMaxLength()
+		bpa[i].MarkKeywordPos([][]rune{keyword})
+	}
+	progress := NewArrangeProgress(o, bpa)
+	progressid := progress.Add("main")
+	am, err := NewArrangeMatrix(o, keyword, 0, 0, bpa, 0, 0, 0, nil, []int{0, 0},
+		NewArrangeWriter(o, 0, keyword), progress, progressid, []int{}, []int{}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	am.Memo = memo
+	err = am.Search(context.Background(), []int{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return am
+}
+
+func TestArrangeMemo(t *testing.T) {
+	// 解がなければ，2つ目のパターンからは探索を省略する
+	memo := NewArrangeMemo(100)
+	am := tSearchMemo(t, memo, "おう")
+	if len(am.MatrixResult) != 0 {
+		t.Errorf("want no result, but returned %v", len(am.MatrixResult))
+	}
+	if memo.Hits == 0 || memo.Len() == 0 {
+		t.Errorf("want dead states and hits, but returned %v states and %v hits", memo.Len(), memo.Hits)
+	}
+
+	// 解があれば，覚えていても結果は変わらない
+	want := len(tSearchMemo(t, nil, "お").MatrixResult)
+	memo = NewArrangeMemo(100)
+	if got := len(tSearchMemo(t, memo, "お").MatrixResult); got != want || want != 2 {
+		t.Errorf("want %v results, but returned %v", want, got)
+	}
+
+	if NewArrangeMemo(0) != nil {
+		t.Errorf("want nil memo for size 0")
+	}
+}
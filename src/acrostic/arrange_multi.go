@@ -0,0 +1,374 @@
+package acrostic
+
+import (
+	"context"
+
+	"github.com/noyuno/lgo/algo"
+)
+
+// ArrangeHidden : --multiで同時に隠すキーワードひとつの探索状態
+type ArrangeHidden struct {
+	// Keyword : キーワード
+	Keyword []rune
+	// Column : 先頭の文字を置く列(-1ならばどこでもよい)
+	Column int
+	// Index : 次に置くキーワードの文字の位置(len(Keyword)ならば置き終わった)
+	Index int
+	// End : 最後に置いたキーワードの文字の位置(行，列)
+	End []int
+}
+
+// HiddenKeyword : 結果に隠れているキーワードひとつ
+type HiddenKeyword struct {
+	// Keyword : キーワード
+	Keyword []rune
+	// KeywordEnd : キーワードの末尾の文字の位置(行，列)
+	KeywordEnd []int
+}
+
+// NewArrangeHidden : constructor
+// column: 先頭の文字を置く列(-1ならばどこでもよい)
+func NewArrangeHidden(keyword []rune, column int) ArrangeHidden {
+	return ArrangeHidden{
+		Keyword: keyword,
+		Column:  column,
+		End:     []int{0, 0},
+	}
+}
+
+// started : キーワードの先頭の文字を置いたかどうか
+func (h *ArrangeHidden) started() bool {
+	return h.Index > 0
+}
+
+// finished : キーワードの文字をすべて置いたかどうか
+func (h *ArrangeHidden) finished() bool {
+	return h.Index >= len(h.Keyword)
+}
+
+// copyHidden : 探索状態をコピーする
+func copyHidden(in []ArrangeHidden) []ArrangeHidden {
+	ret := make([]ArrangeHidden, len(in))
+	for i := range in {
+		ret[i] = in[i]
+		ret[i].End = []int{in[i].End[0], in[i].End[1]}
+	}
+	return ret
+}
+
+// hiddenKeywords : 結果に書き出す隠れたキーワードの一覧
+func hiddenKeywords(in []ArrangeHidden) []HiddenKeyword {
+	ret := make([]HiddenKeyword, len(in))
+	for i := range in {
+		ret[i] = HiddenKeyword{
+			Keyword:    in[i].Keyword,
+			KeywordEnd: []int{in[i].End[0], in[i].End[1]},
+		}
+	}
+	return ret
+}
+
+// hiddenAt : 行row，列colのマスが，hiddenの何番目のキーワードの文字か(どれでもなければ-1)
+func hiddenAt(hidden []HiddenKeyword, step int, row int, col int) int {
+	for i := range hidden {
+		h := hidden[i]
+		if len(h.KeywordEnd) != 2 {
+			continue
+		}
+		start := h.KeywordEnd[0] - len(h.Keyword) + 1
+		if start <= row && row <= h.KeywordEnd[0] && keywordColumnAt(h.KeywordEnd, step, row) == col {
+			return i
+		}
+	}
+	return -1
+}
+
+// resultHidden : 結果の隠れたキーワード．--multiでなければKeywordだけ
+func resultHidden(keyword []rune, keywordend []int, hidden []HiddenKeyword) []HiddenKeyword {
+	if len(hidden) > 0 {
+		return hidden
+	}
+	return []HiddenKeyword{HiddenKeyword{Keyword: keyword, KeywordEnd: keywordend}}
+}
+
+// SearchMulti : Hiddenのキーワードをすべて同時に隠す文を探す(--multi)
+// キーワードごとに，次の文字を置くマスを覚えておき，そのマスに書く文字を確かめる．
+// 並列処理はしない
+func (m *ArrangeMatrix) SearchMulti(ctx context.Context) error {
+	if m.Progress != nil {
+		m.Progress.Set(m.ProgressID, m.PatternStack)
+	}
+	m.Progress.AddNode()
+	if m.hiddenReachable() == false {
+		return nil
+	}
+	state := m.state()
+	if m.Memo.Dead(state) {
+		return nil
+	}
+	defer m.markIfDead(ctx, state)
+	b := m.BasicPhrases[m.BasicPhraseIndex]
+	for pi, p := range b.Pattern {
+		if ctx.Err() != nil {
+			break
+		}
+		if m.searchTop(pi) == false {
+			continue
+		}
+		err := m.searchMultiPattern(ctx, pi, p)
+		if err != nil {
+			return err
+		}
+		if m.Checkpoint != nil {
+			err = m.finishTop(ctx, pi)
+			if err != nil {
+				return err
+			}
+		}
+		if m.Options.One && m.resultLen()+m.wipedLen() > 0 {
+			break
+		}
+	}
+	return m.wipeOutIfFull()
+}
+
+// searchMultiPattern : パターンpについて，まだ置いていないキーワードの先頭の文字をどこに置くかを
+// すべての組み合わせで試す(-1はこのパターンには置かない)
+func (m *ArrangeMatrix) searchMultiPattern(ctx context.Context, pi int, p []rune) error {
+	candidates := make([][]int, len(m.Hidden))
+	for j := range m.Hidden {
+		candidates[j] = []int{-1}
+		if m.Hidden[j].started() {
+			continue
+		}
+		for i := range p {
+			if p[i] == m.Hidden[j].Keyword[0] {
+				candidates[j] = append(candidates[j], i)
+			}
+		}
+	}
+	choice := make([]int, len(m.Hidden))
+	var each func(j int) error
+	each = func(j int) error {
+		if j == len(m.Hidden) {
+			return m.placeMulti(ctx, pi, p, choice)
+		}
+		for _, c := range candidates[j] {
+			choice[j] = c
+			err := each(j + 1)
+			if err != nil {
+				return err
+			}
+			if ctx.Err() != nil || (m.Options.One && m.resultLen() > 0) {
+				break
+			}
+		}
+		return nil
+	}
+	return each(0)
+}
+
+// placeMulti : パターンpを行列に書き，キーワードの文字が正しいマスに来るかを確かめて次の基本句へ進む
+// choice: キーワードごとに，先頭の文字として使うpの文字の位置(-1ならば使わない)
+func (m *ArrangeMatrix) placeMulti(ctx context.Context, pi int, p []rune, choice []int) error {
+	step := m.Options.DirectionStep()
+	hidden := copyHidden(m.Hidden)
+	mat := [][]rune{m.newRow()}
+	matpos := []int{m.MatrixIndex[0], m.MatrixIndex[1]}
+	newline := false
+	if matpos[1] != 0 && m.BasicPhrases[m.BasicPhraseIndex].NewLine {
+		// 改行
+		if m.MatrixIndexMax[0] <= matpos[0]+1 {
+			return nil
+		}
+		// 改行で飛ばすマスにキーワードの文字が来るならば失敗
+		for j := range hidden {
+			h := &hidden[j]
+			if h.started() && h.finished() == false &&
+				h.End[0]+1 == matpos[0] && h.End[1]+step >= matpos[1] {
+				return nil
+			}
+		}
+		newline = true
+		matpos[0]++
+		matpos[1] = 0
+	}
+	matline := matpos[0]
+	placed := false
+	hung := false
+	for i := range p {
+		if m.Options.Kinsoku {
+			h, ok := m.placeKinsoku(mat, matline, p, i, matpos, newline)
+			if ok == false {
+				return nil
+			}
+			if h {
+				// ぶら下げた文字はキーワードの先頭の文字にしない
+				for j := range choice {
+					if choice[j] == i {
+						return nil
+					}
+				}
+				hung = true
+				continue
+			}
+		}
+		for j := range hidden {
+			h := &hidden[j]
+			if choice[j] == i {
+				if m.canStartHidden(hidden, j, matpos) == false {
+					return nil
+				}
+				h.Index = 1
+				h.End = []int{matpos[0], matpos[1]}
+				placed = true
+			} else if h.started() && h.finished() == false &&
+				h.End[0]+1 == matpos[0] && h.End[1]+step == matpos[1] {
+				if h.Keyword[h.Index] != p[i] {
+					return nil
+				}
+				h.Index++
+				h.End = []int{matpos[0], matpos[1]}
+				placed = true
+			}
+		}
+		mat[matpos[0]-matline][matpos[1]] = p[i]
+		flag := 0
+		matpos, flag = algo.SliceAdderR(matpos, m.MatrixIndexMax, len(matpos))
+		if flag == 3 {
+			// 行列からはみ出した
+			return nil
+		} else if flag == 1 && len(p) > i+1 {
+			mat = append(mat, m.newRow())
+		}
+	}
+	if hung {
+		mat = trimHanging(mat, matpos, matline)
+	}
+
+	newstack := make([]int, len(m.PatternStack)+1)
+	copy(newstack, m.PatternStack)
+	newstack[len(newstack)-1] = pi
+	// キーワードの文字を置いた基本句は0(Aパターン)，置かなかった基本句は1(Bパターン)
+	bstack := make([]int, len(m.BranchStack)+1)
+	copy(bstack, m.BranchStack)
+	if placed == false {
+		bstack[len(bstack)-1] = 1
+	}
+	if m.BasicPhraseIndex+1 >= len(m.BasicPhrases) {
+		if m.acceptHidden(hidden, matpos) {
+			r := m.makeResult(mat, matpos, newline, hidden[0].End, newstack, bstack)
+			r.Hidden = hiddenKeywords(hidden)
+			m.addResults(0, r)
+		}
+		return nil
+	}
+	am, err := NewArrangeMatrix(
+		m.Options, m.Keyword, m.KeywordNumber, 0,
+		m.BasicPhrases, m.BasicPhraseIndex+1, m.TextIndex+len(p),
+		m.Number, mat, matpos, m.Writer,
+		m.Progress, m.ProgressID, newstack, bstack, m.Width)
+	if err != nil {
+		return err
+	}
+	am.Hidden = hidden
+	am.Memo = m.Memo
+	am.DisableParallel = true
+	am.Parent = m
+	am.NewLine = newline
+	err = am.SearchMulti(ctx)
+	if err != nil {
+		return err
+	}
+	m.addResults(am.WipedLength, am.MatrixResult...)
+	return nil
+}
+
+// canStartHidden : j番目のキーワードの先頭の文字をposに置けるかどうか
+func (m *ArrangeMatrix) canStartHidden(hidden []ArrangeHidden, j int, pos []int) bool {
+	h := &hidden[j]
+	if h.Column >= 0 && h.Column != pos[1] {
+		return false
+	}
+	if m.Options.MatchLength && pos[0] != 0 {
+		return false
+	}
+	// 最後の文字まで行列の幅に収まるか
+	step := m.Options.DirectionStep()
+	last := pos[1] + step*(len(h.Keyword)-1)
+	if last < 0 || m.Width <= last {
+		return false
+	}
+	// ほかのキーワードと同じ列から始めない
+	for k := range hidden {
+		o := &hidden[k]
+		if k == j || o.started() == false {
+			continue
+		}
+		if keywordColumnAt(o.End, step, o.End[0]-o.Index+1) == pos[1] {
+			return false
+		}
+	}
+	return true
+}
+
+// acceptHidden : すべての基本句を書き終えたときに，結果として受理するかどうか
+// MatchLengthのときは，最後の行が最も長いキーワードの末尾の行と一致しなければならない
+func (m *ArrangeMatrix) acceptHidden(hidden []ArrangeHidden, matpos []int) bool {
+	lastrow := 0
+	for j := range hidden {
+		if hidden[j].finished() == false {
+			return false
+		}
+		if lastrow < hidden[j].End[0] {
+			lastrow = hidden[j].End[0]
+		}
+	}
+	if m.Options.MatchLength == false {
+		return true
+	}
+	// matposは次に書くマスなので，行頭ならば前の行で終わっている
+	row := matpos[0]
+	if matpos[1] == 0 {
+		row--
+	}
+	return row == lastrow
+}
+
+// hiddenReachable : 枝刈り：残りの基本句で，すべてのキーワードの最後の文字まで届くかどうか
+func (m *ArrangeMatrix) hiddenReachable() bool {
+	step := m.Options.DirectionStep()
+	maxremain, newline := m.remainLength()
+	for j := range m.Hidden {
+		h := &m.Hidden[j]
+		if h.finished() {
+			continue
+		}
+		row, col := 0, 0
+		if h.started() {
+			row = h.End[0] + 1
+			col = h.End[1] + step
+			// 次の文字を置くマスを通り過ぎた
+			if m.MatrixIndex[0] > row || (m.MatrixIndex[0] == row && m.MatrixIndex[1] > col) {
+				return false
+			}
+		} else {
+			if m.Options.MatchLength &&
+				(m.MatrixIndex[0] > 0 || (h.Column >= 0 && m.MatrixIndex[1] > h.Column)) {
+				return false
+			}
+			if h.Column < 0 {
+				continue
+			}
+			row = m.MatrixIndex[0]
+			col = h.Column
+			if m.MatrixIndex[1] > col {
+				row++
+			}
+		}
+		if newline == false && m.cellsNeeded(row, col, len(h.Keyword)-1-h.Index) > maxremain {
+			return false
+		}
+	}
+	return true
+}
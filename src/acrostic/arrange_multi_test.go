@@ -0,0 +1,76 @@
+package acrostic
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func tSearchMulti(t *testing.T, o *Options, hidden []ArrangeHidden, surfaces ...string) *ArrangeMatrix {
+	bpa := tNewBasicPhrases(surfaces...)
+	for i := range bpa {
+		bpa[i].Pattern = [][]rune{bpa[i].Surface}
+		bpa[i].UpdatePatternMaxLength()
+	}
+	progress := NewArrangeProgress(o, bpa)
+	progressid := progress.Add("main")
+	am, err := NewArrangeMatrix(o, hidden[0].Keyword, 0, 0, bpa, 0, 0, 0, nil, []int{0, 0},
+		NewArrangeWriter(o, 0, hidden[0].Keyword), progress, progressid, []int{}, []int{}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	am.Hidden = hidden
+	err = am.SearchMulti(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return am
+}
+
+func TestArrangeMatrixSearchMulti(t *testing.T) {
+	o := &Options{Height: 6, MatchLength: true, Silent: true}
+	am := tSearchMulti(t, o, []ArrangeHidden{
+		NewArrangeHidden([]rune("みかん"), -1),
+		NewArrangeHidden([]rune("あうお"), 0),
+	}, "あみい", "うかえ", "おんか")
+	if len(am.MatrixResult) != 1 {
+		t.Fatalf("want 1 result, but returned %v", len(am.MatrixResult))
+	}
+	h := am.MatrixResult[0].Hidden
+	if len(h) != 2 || h[0].KeywordEnd[1] != 1 || h[1].KeywordEnd[1] != 0 {
+		t.Errorf("want みかん at column 1 and あうお at column 0, but returned %v", h)
+	}
+	if ke := am.MatrixResult[0].KeywordEnd; ke[0] != 2 || ke[1] != 1 {
+		t.Errorf("want KeywordEnd [2 1], but returned %v", ke)
+	}
+
+	// 列を固定したキーワードがその列になければ見つからない
+	am = tSearchMulti(t, o, []ArrangeHidden{
+		NewArrangeHidden([]rune("みかん"), -1),
+		NewArrangeHidden([]rune("あうお"), 2),
+	}, "あみい", "うかえ", "おんか")
+	if len(am.MatrixResult) != 0 {
+		t.Errorf("want no result, but returned %v", len(am.MatrixResult))
+	}
+}
+
+func TestRenderHTMLMulti(t *testing.T) {
+	r := tRenderResult()
+	r.Hidden = []HiddenKeyword{
+		HiddenKeyword{Keyword: []rune("みかん"), KeywordEnd: []int{2, 1}},
+		HiddenKeyword{Keyword: []rune("あう<"), KeywordEnd: []int{2, 0}},
+	}
+	b := new(bytes.Buffer)
+	err := RenderHTML(b, r, NewRenderStyle(&Options{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	if n := strings.Count(out, `<td class="acrostic-keyword">`); n != 3 {
+		t.Errorf("want 3 cells of the first keyword, but returned %v: %v", n, out)
+	}
+	if n := strings.Count(out, `<td class="acrostic-keyword acrostic-keyword-1">`); n != 3 {
+		t.Errorf("want 3 cells of the second keyword, but returned %v: %v", n, out)
+	}
+}
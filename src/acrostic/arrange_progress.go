@@ -1,18 +1,45 @@
 package acrostic
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
+// ArrangeProgress : 文パターンひとつ分の探索の進捗
+// 進み具合は，探索中の各goroutineのPatternStackを，基本句ごとのパターンの数(Max)を
+// 桁とする数とみなして求める
 type ArrangeProgress struct {
 	Options *Options
 	Max     []int
 	Current []ArrangeProgressItem
-	ticker  *time.Ticker
-	stop    chan bool
+	// Keyword : キーワードの番号
+	Keyword int
+	// Width : 行の幅
+	Width int
+	// Pattern : 文パターンの番号
+	Pattern int
+	// Total : 探索空間の大きさ(基本句ごとのパターンの数の積．最大math.MaxInt64)
+	Total int64
+	// Events : 進捗をJSONで書き出す先(nilならば書き出さない)
+	Events *ProgressEvents
+	// Display : 進捗を表示する先(nilならば表示しない)
+	Display io.Writer
+	// Nodes : 探索した状態(ArrangeMatrix.Search, SearchMulti)の数
+	Nodes int64
+
+	ticker *time.Ticker
+	stop   chan bool
+	done   chan bool
+	start  time.Time
+	mutex  sync.Mutex
 }
 
 type ArrangeProgressItem struct {
@@ -34,41 +61,88 @@ func NewArrangeProgress(
 	p := int64(1)
 	bpnormal := 0
 	bpmax := 0
-	// 数え上げは直積である
+	// 数え上げは直積である．あふれるときはmath.MaxInt64で止める
 	i := 0
 	for ; i < len(bpa); i++ {
-		p *= int64(len(bpa[i].Pattern))
+		n := int64(len(bpa[i].Pattern))
+		if n != 0 && p > math.MaxInt64/n {
+			p = math.MaxInt64
+		} else {
+			p *= n
+		}
 		bpnormal += len(bpa[i].Surface)
 		bpmax += bpa[i].PatternMaxLength
 	}
+	ret.Total = p
+	if o.Progress && isTerminal(os.Stderr) {
+		ret.Display = os.Stderr
+	}
 	log.Infof("max=%v len=%v p=%v surface=%v-%v",
 		ret.Max, len(ret.Max), p, bpnormal, bpmax)
 	return ret
 }
 
+// isTerminal : fが端末かどうか
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// AddNode : 探索した状態の数を数える
+func (a *ArrangeProgress) AddNode() {
+	if a != nil {
+		atomic.AddInt64(&a.Nodes, 1)
+	}
+}
+
+// enabled : 進捗を記録するかどうか
+func (a *ArrangeProgress) enabled() bool {
+	return a.Display != nil || a.Events != nil
+}
+
 func (a *ArrangeProgress) Add(name string) int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
 	a.Current = append(a.Current, ArrangeProgressItem{
 		Name:    name,
-		Current: make([]int, len(a.Max)),
+		Current: make([]int, 0),
 		Enable:  true,
 	})
-	fmt.Printf("\n")
 	return len(a.Current) - 1
 }
 
 func (a *ArrangeProgress) Remove(id int) {
+	a.mutex.Lock()
 	a.Current[id].Enable = false
+	a.mutex.Unlock()
+}
+
+// SetActive : idのgoroutineが探索しているかどうか(休んでいるワーカーは進み具合に数えない)
+func (a *ArrangeProgress) SetActive(id int, active bool) {
+	a.mutex.Lock()
+	a.Current[id].Enable = active
+	a.Current[id].Current = a.Current[id].Current[:0]
+	a.mutex.Unlock()
 }
 
 func (a *ArrangeProgress) Start() {
+	a.start = time.Now()
+	a.event("start")
 	a.ticker = time.NewTicker(500 * time.Millisecond)
 	a.stop = make(chan bool)
+	a.done = make(chan bool)
+	if a.enabled() == false {
+		close(a.done)
+		return
+	}
 	go func() {
+		defer close(a.done)
 	loop:
 		for {
 			select {
 			case <-a.ticker.C:
 				a.Print()
+				a.event("progress")
 			case <-a.stop:
 				break loop
 			}
@@ -79,30 +153,165 @@ func (a *ArrangeProgress) Start() {
 func (a *ArrangeProgress) Stop() {
 	a.ticker.Stop()
 	close(a.stop)
+	<-a.done
+	if a.Display != nil {
+		// 進捗の行を消す
+		fmt.Fprint(a.Display, "\r\x1b[2K")
+	}
+	a.event("finish")
 }
 
 func (a *ArrangeProgress) Set(id int, stack []int) {
-	if a.Options.Progress {
-		a.Current[id].Current = stack
+	if a.enabled() == false {
+		return
 	}
+	a.mutex.Lock()
+	a.Current[id].Current = append(a.Current[id].Current[:0], stack...)
+	a.mutex.Unlock()
 }
 
-func (a *ArrangeProgress) Print() {
-	//if len(a.Current) == 1 {
-	//	fmt.Printf("\x1b[1K\x1b[0G")
-	//} else {
-	//	fmt.Printf("\x1b[0G", len(a.Current)-1)
-	//	// \x1b[%vF
-	//}
-	fmt.Printf("\x1b[%vA", len(a.Current))
-	for i := range a.Current {
-		if a.Current[i].Enable {
-			fmt.Printf("\x1b[2K%v %v\n", a.Current[i].Name, a.Current[i].Current)
-			//if i+1 < len(a.Current) {
-			//} else {
+// stackFraction : stackより前のパターンの探索を終えたときの進み具合(0以上1以下)
+func (a *ArrangeProgress) stackFraction(stack []int) float64 {
+	ret := 0.0
+	unit := 1.0
+	for i := range stack {
+		if i >= len(a.Max) || a.Max[i] == 0 {
+			break
+		}
+		unit /= float64(a.Max[i])
+		ret += float64(stack[i]) * unit
+	}
+	return ret
+}
 
-			//	fmt.Printf("%v %v", a.Current[i].Name, a.Current[i].Current)
-			//}
+// Fraction : 探索を終えた割合と，探索中のgoroutineの数
+// 最も遅れているgoroutineのPatternStackから求めるので，実際よりも小さめになる
+func (a *ArrangeProgress) Fraction() (float64, int) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	ret := 1.0
+	active := 0
+	for i := range a.Current {
+		if a.Current[i].Enable == false {
+			continue
+		}
+		active++
+		if f := a.stackFraction(a.Current[i].Current); f < ret {
+			ret = f
 		}
 	}
+	if active == 0 {
+		ret = 0
+	}
+	return ret, active
+}
+
+// Remaining : 探索を終えた割合fと経過時間から，残り時間を見積もる(わからなければ負)
+func (a *ArrangeProgress) Remaining(f float64) time.Duration {
+	if f <= 0 {
+		return -1
+	}
+	elapsed := time.Since(a.start)
+	return time.Duration(float64(elapsed) * (1 - f) / f)
+}
+
+// Print : 進み具合と残り時間を1行で表示する
+func (a *ArrangeProgress) Print() {
+	if a.Display == nil {
+		return
+	}
+	f, active := a.Fraction()
+	eta := "?"
+	if r := a.Remaining(f); r >= 0 {
+		eta = r.Round(time.Second).String()
+	}
+	fmt.Fprintf(a.Display, "\r\x1b[2K%v-%v (%v): %5.1f%% elapsed %v eta %v workers %v",
+		a.Keyword, a.Pattern, a.Width, f*100,
+		time.Since(a.start).Round(time.Second), eta, active)
+}
+
+// event : Eventsに進捗を書き出す
+func (a *ArrangeProgress) event(name string) {
+	if a.Events == nil {
+		return
+	}
+	f, active := a.Fraction()
+	if name == "finish" {
+		f = 1
+	}
+	remaining := a.Remaining(f)
+	e := ProgressEvent{
+		Event:     name,
+		Time:      time.Now(),
+		Keyword:   a.Keyword,
+		Width:     a.Width,
+		Pattern:   a.Pattern,
+		Total:     a.Total,
+		Fraction:  f,
+		Elapsed:   time.Since(a.start).Seconds(),
+		Remaining: -1,
+		Workers:   active,
+	}
+	if remaining >= 0 {
+		e.Remaining = remaining.Seconds()
+	}
+	err := a.Events.Write(e)
+	if err != nil {
+		log.Warnf("progress: %v", err.Error())
+	}
+}
+
+// ProgressEvent : --progress-jsonで1行に1件書き出す進捗
+type ProgressEvent struct {
+	// Event : start, progress, finishのいずれか
+	Event string `json:"event"`
+	// Time : 時刻
+	Time time.Time `json:"time"`
+	// Keyword : キーワードの番号
+	Keyword int `json:"keyword"`
+	// Width : 行の幅
+	Width int `json:"width"`
+	// Pattern : 文パターンの番号
+	Pattern int `json:"pattern"`
+	// Total : 探索空間の大きさ
+	Total int64 `json:"total"`
+	// Fraction : 探索を終えた割合(0以上1以下)
+	Fraction float64 `json:"fraction"`
+	// Elapsed : 経過時間(秒)
+	Elapsed float64 `json:"elapsed"`
+	// Remaining : 残り時間の見積もり(秒．わからなければ-1)
+	Remaining float64 `json:"remaining"`
+	// Workers : 探索中のgoroutineの数
+	Workers int `json:"workers"`
+}
+
+// ProgressEvents : ProgressEventを1行に1件ずつ書き出す．並列に呼んでもよい
+type ProgressEvents struct {
+	w     io.Writer
+	mutex sync.Mutex
+}
+
+// NewProgressEvents : constructor
+// name: 書き出すファイル名(-ならば標準エラー出力)．ファイルは続きに書く
+func NewProgressEvents(name string) (*ProgressEvents, error) {
+	if name == "-" {
+		return &ProgressEvents{w: os.Stderr}, nil
+	}
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &ProgressEvents{w: f}, nil
+}
+
+// Write : eを書き出す
+func (p *ProgressEvents) Write(e ProgressEvent) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	_, err = p.w.Write(append(b, '\n'))
+	return err
 }
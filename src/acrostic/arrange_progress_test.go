@@ -0,0 +1,90 @@
+package acrostic
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArrangeProgressFraction(t *testing.T) {
+	bpa := tNewBasicPhrases("あ", "い", "う")
+	bpa[0].Pattern = [][]rune{[]rune("あ"), []rune("か")}
+	bpa[1].Pattern = [][]rune{[]rune("い"), []rune("き"), []rune("し"), []rune("ち")}
+	bpa[2].Pattern = [][]rune{[]rune("う")}
+	b := new(bytes.Buffer)
+	p := NewArrangeProgress(&Options{}, bpa)
+	p.Events = &ProgressEvents{w: b}
+	if p.Total != 8 {
+		t.Errorf("want total 8, but returned %v", p.Total)
+	}
+	main := p.Add("main")
+	worker := p.Add("worker0")
+	p.SetActive(worker, false)
+
+	// 1番目の基本句の2つ目のパターンの，2番目の基本句の3つ目のパターン
+	p.Set(main, []int{1, 2})
+	if f, active := p.Fraction(); f != 0.75 || active != 1 {
+		t.Errorf("want 0.75 by 1 goroutine, but returned %v by %v", f, active)
+	}
+	// 遅れているgoroutineに合わせる
+	p.SetActive(worker, true)
+	p.Set(worker, []int{0, 1})
+	if f, active := p.Fraction(); f != 0.125 || active != 2 {
+		t.Errorf("want 0.125 by 2 goroutines, but returned %v by %v", f, active)
+	}
+
+	p.start = time.Now().Add(-time.Minute)
+	if r := p.Remaining(0.25); r < 2*time.Minute || r > 4*time.Minute {
+		t.Errorf("want about 3m remaining, but returned %v", r)
+	}
+	if r := p.Remaining(0); r >= 0 {
+		t.Errorf("want unknown remaining time, but returned %v", r)
+	}
+}
+
+func TestArrangeProgressTotalOverflow(t *testing.T) {
+	// 64個の基本句にそれぞれ2つのパターンがあると，積はint64に収まらない
+	bpa := make([]BasicPhrase, 64)
+	for i := range bpa {
+		bpa[i].Pattern = [][]rune{[]rune("あ"), []rune("か")}
+	}
+	p := NewArrangeProgress(&Options{}, bpa)
+	if p.Total != math.MaxInt64 {
+		t.Errorf("want total saturated at %v, but returned %v", int64(math.MaxInt64), p.Total)
+	}
+	// パターンのない基本句があれば0
+	bpa = append(bpa, BasicPhrase{})
+	p = NewArrangeProgress(&Options{}, bpa)
+	if p.Total != 0 {
+		t.Errorf("want total 0, but returned %v", p.Total)
+	}
+}
+
+func TestArrangeProgressEvents(t *testing.T) {
+	bpa := tNewBasicPhrases("あ")
+	bpa[0].Pattern = [][]rune{[]rune("あ")}
+	b := new(bytes.Buffer)
+	p := NewArrangeProgress(&Options{}, bpa)
+	p.Events = &ProgressEvents{w: b}
+	p.Pattern = 3
+	p.Add("main")
+	p.Start()
+	p.Stop()
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) < 2 {
+		t.Fatalf("want start and finish events, but returned %v", b.String())
+	}
+	var first, last ProgressEvent
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil {
+		t.Fatal(err)
+	}
+	if first.Event != "start" || last.Event != "finish" || last.Fraction != 1 || last.Pattern != 3 {
+		t.Errorf("unexpected events: %v", b.String())
+	}
+}
@@ -0,0 +1,78 @@
+package acrostic
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// ArrangeScheduler : ArrangeMatrixの探索を分けて並列に行う，大きさの決まったワーカープール
+// どの深さの基本句でも，空いているワーカーがあればパターンひとつ分の探索を渡す．
+// 空いていなければ呼び出した側がそのまま探索するので，ワーカーを待つことはない．
+type ArrangeScheduler struct {
+	// Spawned : ワーカーに渡した探索の数
+	Spawned int64
+
+	// slots : 空いているワーカーの進捗ID
+	slots    chan int
+	progress *ArrangeProgress
+}
+
+// NewArrangeScheduler : constructor
+// n: ワーカーの数(呼び出した側のgoroutineは数えない．1未満ならばnilを返し，並列処理しない)
+// ワーカーごとにprogressの進捗IDを作っておき，使い回す
+func NewArrangeScheduler(n int, progress *ArrangeProgress) *ArrangeScheduler {
+	if n < 1 {
+		return nil
+	}
+	ret := new(ArrangeScheduler)
+	ret.slots = make(chan int, n)
+	ret.progress = progress
+	for i := 0; i < n; i++ {
+		id := 0
+		if progress != nil {
+			id = progress.Add("worker" + strconv.Itoa(i))
+			progress.SetActive(id, false)
+		}
+		ret.slots <- id
+	}
+	return ret
+}
+
+// Go : 空いているワーカーがあればfを実行させてtrueを返す．なければfalseを返す
+// fにはワーカーの進捗IDを渡す．wgで呼び出した側がfの終了を待つ
+func (s *ArrangeScheduler) Go(wg *sync.WaitGroup, f func(progressid int)) bool {
+	if s == nil {
+		return false
+	}
+	select {
+	case id := <-s.slots:
+		atomic.AddInt64(&s.Spawned, 1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { s.slots <- id }()
+			if s.progress != nil {
+				s.progress.SetActive(id, true)
+				defer s.progress.SetActive(id, false)
+			}
+			f(id)
+		}()
+		return true
+	default:
+		return false
+	}
+}
+
+// Close : すべてのワーカーが空くのを待ち，進捗IDを無効にする
+func (s *ArrangeScheduler) Close() {
+	if s == nil {
+		return
+	}
+	for i := 0; i < cap(s.slots); i++ {
+		id := <-s.slots
+		if s.progress != nil {
+			s.progress.Remove(id)
+		}
+	}
+}
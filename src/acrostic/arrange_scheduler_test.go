@@ -0,0 +1,51 @@
+package acrostic
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestArrangeScheduler(t *testing.T) {
+	if NewArrangeScheduler(0, nil) != nil {
+		t.Errorf("want nil scheduler for 0 workers")
+	}
+	var wg sync.WaitGroup
+	var nilscheduler *ArrangeScheduler
+	if nilscheduler.Go(&wg, func(int) {}) {
+		t.Errorf("nil scheduler should not spawn")
+	}
+
+	// ワーカーが埋まっているときは呼び出した側で探索する
+	s := NewArrangeScheduler(1, nil)
+	release := make(chan bool)
+	if s.Go(&wg, func(int) { <-release }) == false {
+		t.Fatalf("want spawned")
+	}
+	if s.Go(&wg, func(int) {}) {
+		t.Errorf("want not spawned while the worker is busy")
+	}
+	close(release)
+	wg.Wait()
+	s.Close()
+	if s.Spawned != 1 {
+		t.Errorf("want 1 spawned, but returned %v", s.Spawned)
+	}
+}
+
+func TestArrangeMatrixSearchScheduler(t *testing.T) {
+	// 並列に探索しても結果は変わらない
+	want := tSearchPatterns(t, func(*ArrangeMatrix) {})
+	if len(want) == 0 {
+		t.Fatalf("want some results")
+	}
+	s := NewArrangeScheduler(3, nil)
+	got := tSearchPatterns(t, func(am *ArrangeMatrix) { am.Scheduler = s })
+	s.Close()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("want %v, but returned %v", want, got)
+	}
+	if s.Spawned == 0 {
+		t.Errorf("want some searches spawned")
+	}
+}
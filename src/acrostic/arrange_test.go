@@ -1,8 +1,12 @@
 package acrostic
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"sort"
 	"testing"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -36,7 +40,7 @@ func TestSentencePattern(t *testing.T) {
 	}
 	i := 0
 	log.Debugf("swap = false")
-	for v := range sentencePattern(in, false) {
+	for v := range sentencePattern(context.Background(), in, false) {
 		o := ""
 		for _, bp := range v {
 			o += string(bp.Surface)
@@ -46,7 +50,7 @@ func TestSentencePattern(t *testing.T) {
 	}
 	i = 0
 	log.Debugf("swap = true")
-	for v := range sentencePattern(in, true) {
+	for v := range sentencePattern(context.Background(), in, true) {
 		o := ""
 		for _, bp := range v {
 			o += string(bp.Surface)
@@ -55,3 +59,279 @@ func TestSentencePattern(t *testing.T) {
 		i++
 	}
 }
+
+func TestSentencePatternCancel(t *testing.T) {
+	// 途中で打ち切ったら，残りのパターンを送らずにチャネルを閉じる
+	in := [][][]BasicPhrase{
+		[][]BasicPhrase{
+			tNewBasicPhrases("こんにちは，", "私の", "名前は", "綾地", "寧々", "です．"),
+			tNewBasicPhrases("こんにちは，", "私の", "名前は", "明日原", "ユウキ", "です．"),
+		},
+		[][]BasicPhrase{
+			tNewBasicPhrases("今年の", "みかんは", "酸味が", "あって", "おいしい．"),
+			tNewBasicPhrases("今年の", "オレンジは", "酸味が", "あって", "おいしい．"),
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c := sentencePattern(ctx, in, true)
+	<-c
+	cancel()
+	done := make(chan int)
+	go func() {
+		for range c {
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("sentencePattern did not stop after cancel")
+	}
+}
+
+func tSearchMatrix(t *testing.T, ctx context.Context) *ArrangeMatrix {
+	o := &Options{Height: 6, MatchLength: true, Silent: true}
+	return tSearchMatrixWith(t, ctx, o, 3, "みかん", "あみい", "うかえ", "おんか")
+}
+
+func tSearchMatrixWith(t *testing.T, ctx context.Context, o *Options, width int,
+	kw string, surfaces ...string) *ArrangeMatrix {
+	keyword := []rune(kw)
+	bpa := tNewBasicPhrases(surfaces...)
+	for i := range bpa {
+		bpa[i].Pattern = [][]rune{bpa[i].Surface}
+		bpa[i].UpdatePatternMaxLength()
+		bpa[i].MarkKeywordPos([][]rune{keyword})
+	}
+	progress := NewArrangeProgress(o, bpa)
+	progressid := progress.Add("main")
+	am, err := NewArrangeMatrix(o, keyword, 0, 0, bpa, 0, 0, 0, nil, []int{0, 0},
+		NewArrangeWriter(o, 0, keyword), progress, progressid, []int{}, []int{}, width)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = am.Search(ctx, []int{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return am
+}
+
+// tSearchPatterns : 最初と3番目の基本句に3つずつパターンがある行列を探索し，結果のパターンのスタックを並べて返す
+func tSearchPatterns(t *testing.T, setup func(*ArrangeMatrix)) []string {
+	o := &Options{Height: 6, Silent: true}
+	keyword := []rune("みか")
+	bpa := tNewBasicPhrases("あい", "み", "お", "か")
+	bpa[0].Pattern = [][]rune{[]rune("あい"), []rune("う"), []rune("えお")}
+	bpa[2].Pattern = [][]rune{[]rune("お"), []rune("おう"), []rune("く")}
+	for i := range bpa {
+		if i == 1 || i == 3 {
+			bpa[i].Pattern = [][]rune{bpa[i].Surface}
+		}
+		bpa[i].UpdatePatternMaxLength()
+		bpa[i].MarkKeywordPos([][]rune{keyword})
+	}
+	progress := NewArrangeProgress(o, bpa)
+	progressid := progress.Add("main")
+	am, err := NewArrangeMatrix(o, keyword, 0, 0, bpa, 0, 0, 0, nil, []int{0, 0},
+		NewArrangeWriter(o, 0, keyword), progress, progressid, []int{}, []int{}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setup(am)
+	err = am.Search(context.Background(), []int{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret := make([]string, len(am.MatrixResult))
+	for i := range am.MatrixResult {
+		ret[i] = fmt.Sprint(am.MatrixResult[i].PatternStack)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func TestArrangeMatrixSearchShard(t *testing.T) {
+	// シャードの結果を合わせると，分けないときの結果と同じになる
+	want := tSearchPatterns(t, func(*ArrangeMatrix) {})
+	o := &Options{ShardCount: 2}
+	got := make([]string, 0)
+	for o.ShardIndex = 0; o.ShardIndex < o.ShardCount; o.ShardIndex++ {
+		shard := make([]bool, 3)
+		for pi := range shard {
+			shard[pi] = o.InShard(pi)
+		}
+		r := tSearchPatterns(t, func(am *ArrangeMatrix) { am.TopPatterns = shard })
+		if len(r) == len(want) {
+			t.Errorf("shard %v should not find all results: %v", o.ShardIndex, r)
+		}
+		got = append(got, r...)
+	}
+	sort.Strings(got)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("want %v, but returned %v", want, got)
+	}
+}
+
+func TestArrangeMatrixSearchContext(t *testing.T) {
+	am := tSearchMatrix(t, context.Background())
+	if len(am.MatrixResult) != 1 {
+		t.Fatalf("want 1 result, but returned %v", len(am.MatrixResult))
+	}
+	mat := am.MatrixResult[0].Matrix
+	if len(mat) != 3 || string([]rune{mat[0][1], mat[1][1], mat[2][1]}) != "みかん" {
+		t.Errorf("want みかん at column 1, but returned %v", mat)
+	}
+	if ke := am.MatrixResult[0].KeywordEnd; ke[0] != 2 || ke[1] != 1 {
+		t.Errorf("want KeywordEnd [2 1], but returned %v", ke)
+	}
+
+	// 打ち切られたときは何も見つからずに戻る
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	am = tSearchMatrix(t, ctx)
+	if len(am.MatrixResult) != 0 {
+		t.Errorf("want no result after cancel, but returned %v", len(am.MatrixResult))
+	}
+}
+
+func TestArrangeMatrixSearchDiagonal(t *testing.T) {
+	for _, c := range []struct {
+		direction string
+		width     int
+		surfaces  []string
+		end       []int
+	}{
+		{"diagonal-right", 3, []string{"みあい", "うかえ", "おえん"}, []int{2, 2}},
+		{"diagonal-left", 4, []string{"あいみう", "えかおく", "んけ"}, []int{2, 0}},
+	} {
+		o := &Options{Height: 6, MatchLength: true, Silent: true, Direction: c.direction}
+		am := tSearchMatrixWith(t, context.Background(), o, c.width, "みかん", c.surfaces...)
+		if len(am.MatrixResult) != 1 {
+			t.Fatalf("%v: want 1 result, but returned %v", c.direction, len(am.MatrixResult))
+		}
+		r := am.MatrixResult[0]
+		if r.KeywordEnd[0] != c.end[0] || r.KeywordEnd[1] != c.end[1] {
+			t.Errorf("%v: want KeywordEnd %v, but returned %v", c.direction, c.end, r.KeywordEnd)
+		}
+		read := make([]rune, 0)
+		for row := 0; row <= r.KeywordEnd[0]; row++ {
+			read = append(read, r.Matrix[row][keywordColumnAt(r.KeywordEnd, r.Step, row)])
+		}
+		if string(read) != "みかん" {
+			t.Errorf("%v: want みかん on the diagonal, but returned %v", c.direction, string(read))
+		}
+	}
+
+	// 縦に並んでいても斜め読みでは見つからない
+	o := &Options{Height: 6, MatchLength: true, Silent: true, Direction: "diagonal-right"}
+	am := tSearchMatrixWith(t, context.Background(), o, 3, "みかん", "あみい", "うかえ", "おんか")
+	if len(am.MatrixResult) != 0 {
+		t.Errorf("want no diagonal result, but returned %v", am.MatrixResult[0].Matrix)
+	}
+}
+
+func TestArrangeMatrixSearchPinnedColumn(t *testing.T) {
+	for _, c := range []struct {
+		column   string
+		surfaces []string
+		want     int
+	}{
+		{"1", []string{"あみい", "うかえ", "おんか"}, 1},
+		{"head", []string{"あみい", "うかえ", "おんか"}, 0},
+		{"last", []string{"あみい", "うかえ", "おんか"}, 0},
+		{"head", []string{"みあい", "かうえ", "んお"}, 1},
+	} {
+		o := &Options{Height: 6, MatchLength: true, Silent: true, Column: c.column}
+		am := tSearchMatrixWith(t, context.Background(), o, 3, "みかん", c.surfaces...)
+		if len(am.MatrixResult) != c.want {
+			t.Errorf("column %v %v: want %v results, but returned %v",
+				c.column, c.surfaces, c.want, len(am.MatrixResult))
+		}
+	}
+}
+
+func TestArrangeMatrixKeywordReachable(t *testing.T) {
+	bpa := tNewBasicPhrases("あいう", "え")
+	for i := range bpa {
+		bpa[i].Pattern = [][]rune{bpa[i].Surface}
+		bpa[i].UpdatePatternMaxLength()
+	}
+	for _, c := range []struct {
+		column string
+		want   bool
+	}{
+		// 「み」を0列目，「か」を1行目の0列目に置くのに必要な4文字がある
+		{"head", true},
+		// 2列目に置くには1行目の2列目まで6文字必要
+		{"last", false},
+		{"", true},
+	} {
+		o := &Options{Height: 6, Column: c.column}
+		am, err := NewArrangeMatrix(o, []rune("みか"), 0, 0, bpa, 0, 0, 0, nil, []int{0, 0},
+			nil, nil, 0, []int{}, []int{}, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r := am.keywordReachable(); r != c.want {
+			t.Errorf("column %v: want %v, but returned %v", c.column, c.want, r)
+		}
+	}
+}
+
+func TestOptionsWithDeadline(t *testing.T) {
+	o := &Options{}
+	ctx, cancel := o.WithDeadline(context.Background())
+	if _, ok := ctx.Deadline(); ok {
+		t.Errorf("want no deadline")
+	}
+	cancel()
+
+	o.Timeout = time.Hour
+	o.DeadlineString = time.Now().Add(30 * time.Minute).Format("2006-01-02 15:04:05")
+	if err := o.parseDeadline(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = o.WithDeadline(context.Background())
+	defer cancel()
+	if d, ok := ctx.Deadline(); !ok || !d.Equal(o.Deadline) {
+		t.Errorf("want the earlier deadline %v, but returned %v", o.Deadline, d)
+	}
+
+	o.DeadlineString = "tomorrow"
+	if err := o.parseDeadline(); err == nil {
+		t.Errorf("want parse error")
+	}
+}
+
+func TestParseDeadlineAt(t *testing.T) {
+	now := time.Date(2018, 1, 2, 12, 0, 0, 0, time.Local)
+	for _, c := range []struct {
+		in   string
+		want time.Time
+	}{
+		// 時刻だけで，まだ来ていなければ今日
+		{"13:00", time.Date(2018, 1, 2, 13, 0, 0, 0, time.Local)},
+		// 時刻だけで，過ぎていれば明日
+		{"11:30", time.Date(2018, 1, 3, 11, 30, 0, 0, time.Local)},
+		{"12:00:00", time.Date(2018, 1, 3, 12, 0, 0, 0, time.Local)},
+		{"2018-01-02 12:30", time.Date(2018, 1, 2, 12, 30, 0, 0, time.Local)},
+	} {
+		o := &Options{DeadlineString: c.in}
+		if err := o.parseDeadlineAt(now); err != nil {
+			t.Errorf("%v: %v", c.in, err)
+			continue
+		}
+		if o.Deadline.Equal(c.want) == false {
+			t.Errorf("%v: want %v, but returned %v", c.in, c.want, o.Deadline)
+		}
+	}
+
+	// 日付のある時刻が過ぎている
+	for _, in := range []string{"2018-01-02 11:59", "2017-12-31 15:04"} {
+		o := &Options{DeadlineString: in}
+		if err := o.parseDeadlineAt(now); err == nil {
+			t.Errorf("%v: want error for a past deadline", in)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package acrostic
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"sync"
@@ -18,8 +20,13 @@ type ArrangeWriter struct {
 	Keyword   []rune
 	Number    int
 	Mutex     sync.RWMutex
+	// Handler : nilでなければ，書き出さずにこれに結果を渡す
+	Handler ResultHandler
 }
 
+// hiddenColors : キーワードの文字の色．--multiのときは，キーワードごとに順に使う
+var hiddenColors = []string{color.FGreen, color.FYellow, color.FBlue, color.FRed}
+
 func NewArrangeWriter(o *Options, kn int, keyword []rune) *ArrangeWriter {
 	ret := new(ArrangeWriter)
 	ret.Options = o
@@ -50,7 +57,7 @@ func (a *ArrangeWriter) Truncate() error {
 
 func (a *ArrangeWriter) OutputKeyword() error {
 	a.Mutex.Lock()
-	if a.Options.OutFileName == "" {
+	if a.Options.OutFileName == "" || a.Options.TextFormat() == false {
 
 	} else {
 		f, err := os.OpenFile(a.Options.OutFileName, os.O_APPEND|os.O_WRONLY, 0644)
@@ -104,6 +111,7 @@ func (a *ArrangeWriter) Output(
 		}
 		count, err = a.write(w, keyword, surfaces, mats, true, begin, width)
 		if err != nil {
+			a.Mutex.Unlock()
 			return nil, err
 		}
 		err = w.Flush()
@@ -145,6 +153,7 @@ func (a *ArrangeWriter) OutputPattern(
 	}
 	count, err := a.writePattern(w, keyword, surface, mreti, mret, writecount, begin, width)
 	if err != nil {
+		a.Mutex.Unlock()
 		return 0, err
 	}
 	err = w.Flush()
@@ -178,15 +187,28 @@ func (a *ArrangeWriter) write(
 		r[i] = 0
 	}
 	var err error
-	for reti, ret := range mats {
-		r[reti], err = a.writePattern(
-			w, keyword, surfaces[reti], reti, ret, writecount, begin[reti], width)
+	if a.Options.Sort == "score" {
+		// 文パターンをまたいでスコアの高い順に書き出す
+		total, err = a.writeRanked(w, keyword, surfaces, mats, begin, width)
 		if err != nil {
 			return nil, err
 		}
-		total += r[reti]
+		for reti := range mats {
+			r[reti] = len(mats[reti])
+		}
+	} else {
+		for reti, ret := range mats {
+			r[reti], err = a.writePattern(
+				w, keyword, surfaces[reti], reti, ret, writecount, begin[reti], width)
+			if err != nil {
+				return nil, err
+			}
+			total += r[reti]
+		}
 	}
-	if total == 0 {
+	if a.Options.TextFormat() == false {
+		// テキスト以外ではコメントを書かない
+	} else if total == 0 {
 		_, err = w.WriteString("# " + T("not found any patterns") + "\n")
 	} else {
 		_, err = w.WriteString(fmt.Sprintf("# "+T("total %v results found")+"\n", total))
@@ -197,6 +219,39 @@ func (a *ArrangeWriter) write(
 	return r, nil
 }
 
+// writeRanked : すべての文パターンの結果をスコアの高い順に並べ，Options.Topまで書き出す
+// return: 見つかった結果の総数
+func (a *ArrangeWriter) writeRanked(
+	w *bufio.Writer,
+	keyword []rune,
+	surfaces [][]rune,
+	mats [][]ArrangeMatrixResult,
+	begin []int,
+	width int) (int, error) {
+	type entry struct {
+		reti int
+		ti   int
+	}
+	entries := make([]entry, 0)
+	all := make([]ArrangeMatrixResult, 0)
+	for reti := range mats {
+		for ti := range mats[reti] {
+			entries = append(entries, entry{reti: reti, ti: ti})
+			all = append(all, mats[reti][ti])
+		}
+	}
+	_, index := RankResults(a.Options, all)
+	for _, i := range index {
+		e := entries[i]
+		_, err := a.writePattern(w, keyword, surfaces[e.reti], e.reti,
+			mats[e.reti][e.ti:e.ti+1], false, begin[e.reti]+e.ti, width)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(all), nil
+}
+
 func (a *ArrangeWriter) writePattern(
 	w *bufio.Writer,
 	keyword []rune,
@@ -208,28 +263,61 @@ func (a *ArrangeWriter) writePattern(
 	width int) (int, error) {
 	T, _ := i18n.Tfunc(a.Options.Language)
 
+	found := len(ret)
+	ret, index := RankResults(a.Options, ret)
+	if a.Handler != nil {
+		for ti := range ret {
+			err := a.Handler(NewResult(keyword, a.Number, width, reti, surface, ret[ti]))
+			if err != nil {
+				return 0, err
+			}
+		}
+		return found, nil
+	}
+	if a.Options.Format == "jsonl" {
+		_, err := a.writeJSONLines(w, keyword, surface, reti, ret, width)
+		if err != nil {
+			return 0, err
+		}
+		return found, nil
+	}
+
 	out := fmt.Sprintf("# %v-%v: %v (%v)\n",
 		a.Number, reti, string(surface), width)
 	//for _, r := range ret {
 	//	out += (string(r.Surface))
 	//}
 	if keyword == nil {
-		log.Fatal("writePattern: keyword == nil")
+		return 0, errors.New("writePattern: keyword == nil")
 	}
 
 	for ti, t := range ret {
-		out += (fmt.Sprintf("# %v-%v-%v stack:%v bstack:%v\n",
-			a.Number, reti, ti+begin, t.PatternStack, t.BranchStack))
+		if a.Options.Sort == "score" {
+			out += (fmt.Sprintf("# %v-%v-%v stack:%v bstack:%v score:%.3f\n",
+				a.Number, reti, index[ti]+begin, t.PatternStack, t.BranchStack, t.Score))
+		} else {
+			out += (fmt.Sprintf("# %v-%v-%v stack:%v bstack:%v\n",
+				a.Number, reti, index[ti]+begin, t.PatternStack, t.BranchStack))
+		}
 		//w.WriteString(fmt.Sprintf("KeywordEnd = %v\n", t.KeywordEnd))
 		if t.KeywordEnd == nil {
 			log.Warnf("writePattern: ArrangeMatrixResult[%v].KeywordEnd == nil", ti)
 			continue
 		}
 		if len(t.KeywordEnd) != 2 {
-			log.Warnf("writePattern: ArrangeMatrixResult[%v].KeywordEnd length is %v", len(t.KeywordEnd))
+			log.Warnf("writePattern: ArrangeMatrixResult[%v].KeywordEnd length is %v", ti, len(t.KeywordEnd))
+			continue
+		}
+		hidden := resultHidden(keyword, t.KeywordEnd, t.Hidden)
+		if a.Options.Tategaki {
+			out += TategakiText(t.Matrix, func(c rune, ri int, ci int) string {
+				if hi := hiddenAt(hidden, t.Step, ri, ci); a.Color && hi >= 0 {
+					return hiddenColors[hi%len(hiddenColors)] + Wide(c) + color.Reset
+				}
+				return Wide(c)
+			})
 			continue
 		}
-		startrow := t.KeywordEnd[0] - len(keyword) + 1
 		for ri, r := range t.Matrix {
 			//end := false
 			for ci, c := range r {
@@ -237,10 +325,8 @@ func (a *ArrangeWriter) writePattern(
 					//end = true
 					break
 				}
-				if a.Color &&
-					startrow <= ri && ri <= t.KeywordEnd[0] &&
-					t.KeywordEnd[1] == ci {
-					out += (color.FGreen + Wide(c) + color.Reset)
+				if hi := hiddenAt(hidden, t.Step, ri, ci); a.Color && hi >= 0 {
+					out += (hiddenColors[hi%len(hiddenColors)] + Wide(c) + color.Reset)
 				} else {
 					out += Wide(c)
 				}
@@ -252,10 +338,10 @@ func (a *ArrangeWriter) writePattern(
 		}
 	}
 	if writecount {
-		if len(ret)+begin == 0 {
+		if found+begin == 0 {
 			out += "# " + T("not found") + "\n"
 		} else {
-			out += (fmt.Sprintf("# "+T("%v results found")+"\n", len(ret)+begin))
+			out += (fmt.Sprintf("# "+T("%v results found")+"\n", found+begin))
 		}
 	}
 	_, err := w.WriteString(out)
@@ -263,5 +349,27 @@ func (a *ArrangeWriter) writePattern(
 		return 0, err
 	}
 
+	return found, nil
+}
+
+// writeJSONLines : 1行に1件ずつResultRecordを書き出す
+func (a *ArrangeWriter) writeJSONLines(
+	w *bufio.Writer,
+	keyword []rune,
+	surface []rune,
+	reti int,
+	ret []ArrangeMatrixResult,
+	width int) (int, error) {
+	for ti := range ret {
+		b, err := json.Marshal(NewResultRecord(
+			NewResult(keyword, a.Number, width, reti, surface, ret[ti])))
+		if err != nil {
+			return 0, err
+		}
+		_, err = w.Write(append(b, '\n'))
+		if err != nil {
+			return 0, err
+		}
+	}
 	return len(ret), nil
 }
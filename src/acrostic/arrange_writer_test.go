@@ -0,0 +1,92 @@
+package acrostic
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestArrangeWriterHandler(t *testing.T) {
+	o := &Options{OutputEachPattern: true}
+	w := NewArrangeWriter(o, 1, []rune("みかん"))
+	results := make([]Result, 0)
+	w.Handler = func(r Result) error {
+		results = append(results, r)
+		return nil
+	}
+	mret := []ArrangeMatrixResult{
+		ArrangeMatrixResult{
+			Matrix: [][]rune{
+				[]rune("あみい"),
+				[]rune("うかえ"),
+				[]rune("おんか"),
+			},
+			KeywordEnd:   []int{2, 1},
+			PatternStack: []int{0, 1},
+			BranchStack:  []int{0, 0},
+		},
+	}
+	n, err := w.OutputPattern([]rune("みかん"), []rune("あみいうかえおんか"), 3, mret, true, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || len(results) != 1 {
+		t.Fatalf("want 1 result, but returned %v (%v)", n, len(results))
+	}
+	r := results[0]
+	if r.KeywordNumber != 1 || r.PatternNumber != 3 || r.Width != 3 {
+		t.Errorf("want KeywordNumber=1 PatternNumber=3 Width=3, but returned %v %v %v",
+			r.KeywordNumber, r.PatternNumber, r.Width)
+	}
+	if string(r.Surface) != "あみいうかえおんか" {
+		t.Errorf("want surface あみいうかえおんか, but returned %v", string(r.Surface))
+	}
+	if r.KeywordEnd[0] != 2 || r.KeywordEnd[1] != 1 {
+		t.Errorf("want KeywordEnd [2 1], but returned %v", r.KeywordEnd)
+	}
+}
+
+func TestArrangeWriterJSONLines(t *testing.T) {
+	o := &Options{OutputEachPattern: true, Format: "jsonl"}
+	w := NewArrangeWriter(o, 1, []rune("みかん"))
+	buf := new(bytes.Buffer)
+	w.StdWriter = bufio.NewWriter(buf)
+	mret := []ArrangeMatrixResult{
+		ArrangeMatrixResult{
+			Matrix: [][]rune{
+				[]rune("あみい"),
+				[]rune("うかえ"),
+				[]rune{'お', 'ん', 0},
+			},
+			KeywordEnd:   []int{2, 1},
+			PatternStack: []int{0, 1},
+			BranchStack:  []int{0, 0},
+		},
+	}
+	n, err := w.OutputPattern([]rune("みかん"), []rune("あみいうかえおん"), 3, mret, true, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("want 1 result, but returned %v", n)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("want 1 line, but returned %v: %v", len(lines), buf.String())
+	}
+	var r ResultRecord
+	if err = json.Unmarshal([]byte(lines[0]), &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Keyword != "みかん" || r.KeywordIndex != 1 || r.PatternIndex != 3 || r.Width != 3 {
+		t.Errorf("unexpected record: %+v", r)
+	}
+	if len(r.Rows) != 3 || r.Rows[0] != "あみい" || r.Rows[2] != "おん" {
+		t.Errorf("unexpected rows: %v", r.Rows)
+	}
+	if r.KeywordColumn != (ResultKeywordColumn{Column: 1, StartColumn: 1, StartRow: 0, EndRow: 2}) {
+		t.Errorf("unexpected keyword column: %+v", r.KeywordColumn)
+	}
+}
@@ -2,12 +2,17 @@ package acrostic
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/noyuno/lgo/runes"
 	log "github.com/sirupsen/logrus"
 )
 
+// politePatterns : 類義語の丁寧形(PoliteSurface, PoliteKana)をパターンに加えるか
+// 丁寧形のパターンが正しいかまだ確かめていないので，加えない
+const politePatterns = false
+
 // BasicPhrase : 自立語の基本句(+から始まる)および形態素(*+以外から始まる)
 type BasicPhrase struct {
 	// Options : オプション
@@ -143,6 +148,8 @@ type BasicPhrase struct {
 
 	// 類義語およびそのかなのをぜんぶまとめたもの
 	Pattern [][]rune
+	// PatternSource : Patternのそれぞれがどのように作られたか(Patternと同じ順番)
+	PatternSource []PatternSource
 
 	PatternLengthMap map[int]bool
 
@@ -251,6 +258,7 @@ func (b *BasicPhrase) Copy() *BasicPhrase {
 		r.InflectionForm = runes.Copy(b.InflectionForm)
 		r.Domain = runes.Copy(b.Domain)
 		r.Pattern = runes.CopyArray(b.Pattern)
+		r.PatternSource = append([]PatternSource(nil), b.PatternSource...)
 	} else {
 		r.Part = b.Part
 		r.BasicPhrase = b.BasicPhrase
@@ -296,6 +304,7 @@ func (b *BasicPhrase) Copy() *BasicPhrase {
 		r.InflectionForm = b.InflectionForm
 		r.Domain = b.Domain
 		r.Pattern = b.Pattern
+		r.PatternSource = b.PatternSource
 	}
 	return r
 }
@@ -306,19 +315,27 @@ func (bp *BasicPhrase) AppendParaphrase(s []rune) {
 	if !runes.Compare(s, para) {
 		log.Debugf("%v can paraphrase into %v", string(s), string(para))
 		bp.Pattern = append(bp.Pattern, para)
+		bp.PatternSource = append(bp.PatternSource, PatternSource{Kind: PatternParaphrase})
 	}
 }
 
 // sにAdjunct, Suffix, Special, Determineを付加してPatternsに追加する
-func (bp *BasicPhrase) AppendPattern(s []rune, suffix bool, onlykeywords bool) {
+// source: sの由来(スコアの計算に使う)
+// 扱えない品詞が含まれていればErrUnknownPartを返す
+func (bp *BasicPhrase) AppendPattern(s []rune, source PatternSource, suffix bool, onlykeywords bool) error {
 	//log.Debugf("AppendPattern: %v", string(s))
-	bp.AppendPatternBase(s, bp.DetermineSurface, suffix, onlykeywords)
+	err := bp.AppendPatternBase(s, source, bp.DetermineSurface, suffix, onlykeywords)
+	if err != nil {
+		return err
+	}
 	if !runes.Compare(bp.DetermineSurface, bp.DetermineOrigin) {
-		bp.AppendPatternBase(s, bp.DetermineOrigin, suffix, onlykeywords)
+		return bp.AppendPatternBase(s, source, bp.DetermineOrigin, suffix, onlykeywords)
 	}
+	return nil
 }
 
-func (bp *BasicPhrase) AppendPatternBase(s []rune, determine []rune, suffix bool, onlykeywords bool) {
+func (bp *BasicPhrase) AppendPatternBase(
+	s []rune, source PatternSource, determine []rune, suffix bool, onlykeywords bool) error {
 	ret := make([]rune, 0)
 	particle := 0
 	suf := 0
@@ -336,6 +353,9 @@ func (bp *BasicPhrase) AppendPatternBase(s []rune, determine []rune, suffix bool
 			}
 		}
 	}
+	if source.Kana && len(source.Base) > 0 {
+		source.Ruby = newPatternRuby(len(ret), s, source.Base)
+	}
 	ret = append(ret, s...)
 	for _, part := range bp.SurfaceOrder {
 		if part == AuxiliaryVerbPart {
@@ -364,7 +384,7 @@ func (bp *BasicPhrase) AppendPatternBase(s []rune, determine []rune, suffix bool
 			part == DemonstrativePart {
 			// do not anything
 		} else {
-			log.Fatalf("BasicPhrase.AppendPatternBase: unknown part: %v", part.String())
+			return fmt.Errorf("BasicPhrase.AppendPatternBase: %w: %v", ErrUnknownPart, part.String())
 		}
 	}
 	// 同じ文字列長の単語が入っているか
@@ -373,13 +393,14 @@ func (bp *BasicPhrase) AppendPatternBase(s []rune, determine []rune, suffix bool
 			// force
 			// すでに入っているわけがない
 			bp.Pattern = append(bp.Pattern, ret)
+			bp.PatternSource = append(bp.PatternSource, source)
 			bp.PatternLengthMap[len(ret)] = true
-			return
+			return nil
 		}
 	}
 	if onlykeywords && bp.Options.OnlyKeywords {
 		if bp.HasKeyword(string(ret)) == false {
-			return
+			return nil
 		}
 	}
 	// キーワードの文字が入っているか
@@ -397,17 +418,19 @@ func (bp *BasicPhrase) AppendPatternBase(s []rune, determine []rune, suffix bool
 			}
 		}
 		if found == false {
-			return
+			return nil
 		}
 	}
 	// すでに入っているか
 	for i := range bp.Pattern {
 		if runes.Compare(bp.Pattern[i], ret) {
-			return
+			return nil
 		}
 	}
 	bp.Pattern = append(bp.Pattern, ret)
+	bp.PatternSource = append(bp.PatternSource, source)
 	bp.PatternLengthMap[len(ret)] = true
+	return nil
 }
 
 func (bp *BasicPhrase) Analyze() error {
@@ -610,11 +633,13 @@ func (bp *BasicPhrase) Given(s string) bool {
 func (bp *BasicPhrase) RemoveSameLengthPattern() {
 	le := map[int]bool{}
 	array := make([][]rune, 0)
+	sources := make([]PatternSource, 0)
 	for i := range bp.Pattern {
 		p := string(bp.Pattern[i])
 		if bp.HasKeyword(p) || bp.Given(p) {
 			//log.Debugf("%v contains keyword", string(bp.Pattern[i]))
 			array = append(array, bp.Pattern[i])
+			sources = append(sources, bp.Source(i))
 			le[len(bp.Pattern[i])] = true
 		} else {
 			//log.Debugf("%v does not contain keyword", string(bp.Pattern[i]))
@@ -625,14 +650,26 @@ func (bp *BasicPhrase) RemoveSameLengthPattern() {
 			//log.Debugf("length %v is not in Pattern, insert %v",
 			//	len(bp.Pattern[i]), string(bp.Pattern[i]))
 			array = append(array, bp.Pattern[i])
+			sources = append(sources, bp.Source(i))
 			le[len(bp.Pattern[i])] = true
 		}
 	}
 	bp.Pattern = bp.Pattern[:0]
 	bp.Pattern = array
+	bp.PatternSource = sources
 }
 
-func (bp *BasicPhrase) UpdateSurface() {
+// Source : i番目のパターンの由来(記録されていなければ元の表層とみなす)
+func (bp *BasicPhrase) Source(i int) PatternSource {
+	if i < len(bp.PatternSource) {
+		return bp.PatternSource[i]
+	}
+	return PatternSource{}
+}
+
+// UpdateSurface : SurfaceOrderにしたがって表層を作り直す
+// 扱えない品詞が含まれていればErrUnknownPartを返す
+func (bp *BasicPhrase) UpdateSurface() error {
 	independent := 0
 	suffix := 0
 	particle := 0
@@ -672,23 +709,30 @@ func (bp *BasicPhrase) UpdateSurface() {
 				}
 			}
 		} else {
-			log.Fatalf("unknown Part: %v", part.String())
+			return fmt.Errorf("BasicPhrase.UpdateSurface: %w: %v", ErrUnknownPart, part.String())
 		}
 	}
 	log.Debugf("BasicPhrase.UpdateSurface %v -> %v",
 		string(oldsurface), string(bp.Surface))
+	return nil
 }
 
 func (bp *BasicPhrase) UpdatePattern() error {
 	var err error
 	bp.Pattern = make([][]rune, 0)
+	bp.PatternSource = make([]PatternSource, 0)
 
 	if bp.Options.UseKanji {
 		bp.Pattern = append(bp.Pattern, bp.Surface)
+		bp.PatternSource = append(bp.PatternSource, PatternSource{Kind: PatternSurface})
 		bp.AppendParaphrase(bp.Surface)
 	}
 	if bp.Options.UseKana {
-		bp.AppendPattern(bp.Kana, true, false)
+		err = bp.AppendPattern(bp.Kana,
+			PatternSource{Kind: PatternSurface, Kana: true, Base: bp.AllIndependentSurface}, true, false)
+		if err != nil {
+			return err
+		}
 	}
 
 	// 丁寧語
@@ -706,12 +750,18 @@ func (bp *BasicPhrase) UpdatePattern() error {
 				}
 				a = append(a, i...)
 				if bp.Options.UseKanji {
-					bp.AppendPattern(a, false, false)
+					err = bp.AppendPattern(a, PatternSource{Kind: PatternPolite}, false, false)
+					if err != nil {
+						return err
+					}
 				}
 				if bp.Options.UseKana {
 					k, f := bp.Instance.Kana.Get(a)
 					if f {
-						bp.AppendPattern(k, true, false)
+						err = bp.AppendPattern(k, PatternSource{Kind: PatternPolite, Kana: true, Base: a}, true, false)
+						if err != nil {
+							return err
+						}
 					} else {
 						log.Warnf("could not get kana: %v", string(a))
 					}
@@ -730,12 +780,18 @@ func (bp *BasicPhrase) UpdatePattern() error {
 				}
 				a = append(a, p...)
 				if bp.Options.UseKanji {
-					bp.AppendPattern(a, false, false)
+					err = bp.AppendPattern(a, PatternSource{Kind: PatternPolite}, false, false)
+					if err != nil {
+						return err
+					}
 				}
 				if bp.Options.UseKana {
 					k, f := bp.Instance.Kana.Get(a)
 					if f {
-						bp.AppendPattern(k, true, false)
+						err = bp.AppendPattern(k, PatternSource{Kind: PatternPolite, Kana: true, Base: a}, true, false)
+						if err != nil {
+							return err
+						}
 					} else {
 						log.Warnf("could not get kana: %v", string(a))
 					}
@@ -757,26 +813,51 @@ func (bp *BasicPhrase) UpdatePattern() error {
 		}
 		bp.Synonyms = wnr
 		for _, s := range bp.Synonyms {
+			source := bp.synonymSource(s)
+			kana := source
+			kana.Kana = true
 			if s.HasInflection {
 				if bp.Options.UseKanji {
-					bp.AppendPattern(s.InflectionSurface, true, true)
+					err = bp.AppendPattern(s.InflectionSurface, source, true, true)
+					if err != nil {
+						return err
+					}
 				}
 				if s.HasPolite {
-					log.Fatalf("polite found: %v", string(s.PoliteSurface))
+					log.Debugf("polite found: %v", string(s.PoliteSurface))
+				}
+				if s.HasPolite && politePatterns {
 					if bp.Options.UseKanji {
-						bp.AppendPattern(s.PoliteSurface, true, true)
+						err = bp.AppendPattern(s.PoliteSurface, source, true, true)
+						if err != nil {
+							return err
+						}
 					}
 					if bp.Options.UseKana {
-						bp.AppendPattern(s.PoliteKana, true, true)
+						kana.Base = s.PoliteSurface
+						err = bp.AppendPattern(s.PoliteKana, kana, true, true)
+						if err != nil {
+							return err
+						}
 					}
 				}
 			} else {
 				if bp.Options.UseKanji {
-					bp.AppendPattern(s.Surface, true, true)
+					err = bp.AppendPattern(s.Surface, source, true, true)
+					if err != nil {
+						return err
+					}
 				}
 			}
 			if s.HasKana && bp.Options.UseKana {
-				bp.AppendPattern(s.Kana, true, true)
+				kana.Base = s.Surface
+				if s.HasInflection {
+					kana.Base = s.InflectionSurface
+				}
+				err = bp.AppendPattern(s.Kana, kana, true, true)
+				if err != nil {
+					return err
+				}
 			}
 		}
 		// test whether bp.Synonyms contains other part
@@ -797,8 +878,12 @@ func (bp *BasicPhrase) UpdatePattern() error {
 	// 枝刈りでも防げないときは，それぞれのパターン数を制限するしかない
 	if len(bp.Pattern) > bp.Options.WordPatternLimit {
 		bp.Pattern = bp.Pattern[:bp.Options.WordPatternLimit]
+		if len(bp.PatternSource) > bp.Options.WordPatternLimit {
+			bp.PatternSource = bp.PatternSource[:bp.Options.WordPatternLimit]
+		}
 	}
 	bp.UpdatePatternMaxLength()
+	bp.UpdatePatternScore(DefaultScoreWeights)
 
 	bp.MarkKeywordPos(bp.Keywords)
 
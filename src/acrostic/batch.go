@@ -0,0 +1,348 @@
+package acrostic
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// BatchDocument : batchで処理する文書ひとつ
+type BatchDocument struct {
+	// ID : 文書の名前(結果のファイル名にする)
+	ID string `json:"id"`
+	// Text : テキスト(行ごとに改行で終わる)
+	Text string `json:"text"`
+	// Keywords : この文書だけのキーワード(空ならば-kのキーワード)
+	Keywords []string `json:"keywords,omitempty"`
+}
+
+// BatchReport : batchの結果の要約(summary.json)
+type BatchReport struct {
+	// Documents : 文書ごとの結果
+	Documents []BatchDocumentReport `json:"documents"`
+	// Combinations : キーワードと幅の組ごとに，結果が見つかった文書の数
+	Combinations []BatchCombination `json:"combinations"`
+}
+
+// BatchDocumentReport : 文書ひとつの結果
+type BatchDocumentReport struct {
+	// ID : 文書の名前
+	ID string `json:"id"`
+	// File : 結果のファイル名(出力ディレクトリからの相対パス)
+	File string `json:"file,omitempty"`
+	// Status : ok, not-found, truncated, error
+	Status string `json:"status"`
+	// Error : Statusがerrorのときの理由
+	Error string `json:"error,omitempty"`
+	// Results : 見つかった結果の数
+	Results int `json:"results"`
+	// Elapsed : かかった時間(秒)
+	Elapsed float64 `json:"elapsed"`
+	// Combinations : キーワードと幅の組ごとの結果の数(0ならば見つからなかった)
+	Combinations []BatchCombination `json:"combinations,omitempty"`
+}
+
+// BatchCombination : キーワードと幅の組ひとつ
+type BatchCombination struct {
+	// Keyword : キーワード(--multiのときは空白で区切ったすべてのキーワード)
+	Keyword string `json:"keyword"`
+	// Width : 行の幅
+	Width int `json:"width"`
+	// Results : 文書ごとのときは結果の数，Combinationsのときは結果が見つかった文書の数
+	Results int `json:"results"`
+}
+
+// ReadBatchDocuments : batchの入力を読み取る
+// ディレクトリならばその中のファイルをひとつずつ，拡張子が.jsonlならば1行ずつ(BatchDocument)，
+// それ以外は空行で区切られた部分をひとつずつ文書とする
+func ReadBatchDocuments(name string) ([]BatchDocument, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return readBatchDirectory(name)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if filepath.Ext(name) == ".jsonl" {
+		return readBatchJSONLines(f)
+	}
+	return readBatchParagraphs(f)
+}
+
+// readBatchDirectory : ディレクトリの中のファイル(隠しファイルを除く)を名前の順に文書とする
+func readBatchDirectory(dir string) ([]BatchDocument, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]BatchDocument, 0)
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		text := make([][]rune, 0)
+		err = readFileA1(filepath.Join(dir, e.Name()), &text)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, BatchDocument{
+			ID:   strings.TrimSuffix(e.Name(), filepath.Ext(e.Name())),
+			Text: strings.TrimSuffix(string(text[0]), "\n"),
+		})
+	}
+	return ret, nil
+}
+
+// readBatchJSONLines : 1行にひとつのBatchDocument．IDがなければ行番号にする
+func readBatchJSONLines(r io.Reader) ([]BatchDocument, error) {
+	ret := make([]BatchDocument, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var d BatchDocument
+		err := json.Unmarshal(scanner.Bytes(), &d)
+		if err != nil {
+			return nil, fmt.Errorf("batch: line %v: %w", line, err)
+		}
+		if d.ID == "" {
+			d.ID = strconv.Itoa(line)
+		}
+		if strings.HasSuffix(d.Text, "\n") == false {
+			d.Text += "\n"
+		}
+		ret = append(ret, d)
+	}
+	return ret, scanner.Err()
+}
+
+// readBatchParagraphs : 空行で区切られた部分をひとつずつ文書とする．IDは1からの番号
+func readBatchParagraphs(r io.Reader) ([]BatchDocument, error) {
+	ret := make([]BatchDocument, 0)
+	scanner := bufio.NewScanner(r)
+	text := ""
+	flush := func() {
+		if text != "" {
+			ret = append(ret, BatchDocument{ID: strconv.Itoa(len(ret) + 1), Text: text})
+			text = ""
+		}
+	}
+	for scanner.Scan() {
+		t := scanner.Text()
+		if strings.TrimSpace(t) == "" {
+			flush()
+			continue
+		}
+		text += t + "\n"
+	}
+	flush()
+	return ret, scanner.Err()
+}
+
+// batchExtension : Options.Formatで書き出す結果のファイルの拡張子
+func batchExtension(o *Options) string {
+	switch o.Format {
+	case "jsonl", "html", "svg":
+		return "." + o.Format
+	case "aozora":
+		return ".txt"
+	case "latex":
+		return ".tex"
+	}
+	return ".json"
+}
+
+// batchFileName : 文書のIDから結果のファイル名を作る
+func batchFileName(o *Options, id string) string {
+	id = strings.Map(func(c rune) rune {
+		if c == '/' || c == '\\' || c == os.PathSeparator {
+			return '_'
+		}
+		return c
+	}, id)
+	return id + batchExtension(o)
+}
+
+// RunBatch : 文書をひとつずつ，同じInstanceで縦読み化し，dirに文書ごとの結果とsummary.jsonを書き出す
+// 文書の失敗は要約に記録して次の文書へ進む．ctxが終了したときは残りの文書を処理しない
+func RunBatch(ctx context.Context, o *Options, instance *Instance,
+	docs []BatchDocument, keywords [][]rune, columns []string, dir string) (*BatchReport, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	report := &BatchReport{
+		Documents:    make([]BatchDocumentReport, 0, len(docs)),
+		Combinations: make([]BatchCombination, 0),
+	}
+	for i := range docs {
+		if ctx.Err() != nil {
+			break
+		}
+		d, err := runBatchDocument(ctx, o, instance, docs[i], keywords, columns, dir)
+		if err != nil {
+			return report, err
+		}
+		log.Infof("batch: %v: %v, %v results", d.ID, d.Status, d.Results)
+		report.Documents = append(report.Documents, d)
+	}
+	report.Combinations = batchCombinations(report.Documents)
+
+	f, err := os.Create(filepath.Join(dir, "summary.json"))
+	if err != nil {
+		return report, err
+	}
+	defer f.Close()
+	e := json.NewEncoder(f)
+	e.SetIndent("", "  ")
+	return report, e.Encode(report)
+}
+
+// runBatchDocument : 文書ひとつを縦読み化して結果を書き出す
+// 書き出せなかったときだけエラーを返す
+func runBatchDocument(ctx context.Context, o *Options, instance *Instance,
+	doc BatchDocument, keywords [][]rune, columns []string, dir string) (BatchDocumentReport, error) {
+	ret := BatchDocumentReport{ID: doc.ID, Status: "ok"}
+	if len(doc.Keywords) > 0 {
+		keywords = make([][]rune, len(doc.Keywords))
+		for i := range doc.Keywords {
+			keywords[i] = []rune(doc.Keywords[i])
+		}
+		columns = nil
+	}
+	start := time.Now()
+	results, err := Run(ctx, Request{
+		Options:        o,
+		Instance:       instance,
+		Text:           []rune(doc.Text),
+		Keywords:       keywords,
+		KeywordColumns: columns,
+	})
+	ret.Elapsed = time.Since(start).Seconds()
+	ret.Results = len(results)
+	ret.Status = runStatus(err, len(results))
+	if ret.Status == "error" {
+		ret.Error = err.Error()
+	}
+	ret.Combinations = batchDocumentCombinations(o, keywords, results)
+	if len(results) == 0 {
+		return ret, nil
+	}
+	ret.File = batchFileName(o, doc.ID)
+	f, err := os.Create(filepath.Join(dir, ret.File))
+	if err != nil {
+		return ret, err
+	}
+	defer f.Close()
+	return ret, WriteResults(f, o, results)
+}
+
+// runStatus : Runのエラーと結果の数から，ok, not-found, truncated, errorのいずれかを返す
+func runStatus(err error, results int) string {
+	switch {
+	case errors.Is(err, ErrKeywordNotFound):
+		return "not-found"
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled):
+		return "truncated"
+	case err != nil:
+		return "error"
+	case results == 0:
+		return "not-found"
+	}
+	return "ok"
+}
+
+// batchKeyword : 要約に書くキーワード．--multiのときはすべてのキーワードを空白で区切る
+// 結果のキーワードと比べるので，--full-widthのときは全角にする
+func batchKeyword(o *Options, keywords [][]rune, kn int) string {
+	s := make([]string, 0, len(keywords))
+	for i := range keywords {
+		if o.Multi || i == kn {
+			k := keywords[i]
+			if o.FullWidth {
+				k = ToFullWidth(k)
+			}
+			s = append(s, string(k))
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+// batchDocumentCombinations : キーワードと幅の組ごとに結果を数える(見つからなかった組は0)
+func batchDocumentCombinations(o *Options, keywords [][]rune, results []Result) []BatchCombination {
+	maxwidth := o.MaxWidth
+	if maxwidth < o.Width {
+		maxwidth = o.Width
+	}
+	n := len(keywords)
+	if o.Multi && n > 0 {
+		n = 1
+	}
+	ret := make([]BatchCombination, 0, n*(maxwidth-o.Width+1))
+	index := map[string]int{}
+	for k := 0; k < n; k++ {
+		for w := o.Width; w <= maxwidth; w++ {
+			c := BatchCombination{Keyword: batchKeyword(o, keywords, k), Width: w}
+			index[fmt.Sprintf("%v %v", c.Keyword, c.Width)] = len(ret)
+			ret = append(ret, c)
+		}
+	}
+	for _, r := range results {
+		key := fmt.Sprintf("%v %v", string(r.Keyword), r.Width)
+		if o.Multi {
+			key = fmt.Sprintf("%v %v", batchKeyword(o, keywords, 0), r.Width)
+		}
+		if i, ok := index[key]; ok {
+			ret[i].Results++
+		}
+	}
+	return ret
+}
+
+// batchCombinations : キーワードと幅の組ごとに，結果が見つかった文書を数える
+func batchCombinations(docs []BatchDocumentReport) []BatchCombination {
+	count := map[BatchCombination]int{}
+	for _, d := range docs {
+		for _, c := range d.Combinations {
+			key := BatchCombination{Keyword: c.Keyword, Width: c.Width}
+			if _, ok := count[key]; ok == false {
+				count[key] = 0
+			}
+			if c.Results > 0 {
+				count[key]++
+			}
+		}
+	}
+	ret := make([]BatchCombination, 0, len(count))
+	for c, n := range count {
+		c.Results = n
+		ret = append(ret, c)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Keyword != ret[j].Keyword {
+			return ret[i].Keyword < ret[j].Keyword
+		}
+		return ret[i].Width < ret[j].Width
+	})
+	return ret
+}
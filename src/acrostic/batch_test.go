@@ -0,0 +1,121 @@
+package acrostic
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadBatchDocuments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acrostic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	docs := filepath.Join(dir, "docs")
+	err = os.Mkdir(docs, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"docs/a.txt":   "みかんは\n\nあまい。\n",
+		"docs/b.txt":   "かきを\n",
+		"docs/.hidden": "x\n",
+		"corpus.txt":   "みかんは\nあまい。\n\n\nかきを\n",
+		"corpus.jsonl": `{"id": "m", "text": "みかんはあまい。", "keywords": ["みあ"]}` + "\n\n" + `{"text": "かきを\n"}` + "\n",
+	}
+	for name, text := range files {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(text), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range []struct {
+		name string
+		want []BatchDocument
+	}{
+		{"docs", []BatchDocument{{ID: "a", Text: "みかんは\nあまい。\n"}, {ID: "b", Text: "かきを\n"}}},
+		{"corpus.txt", []BatchDocument{{ID: "1", Text: "みかんは\nあまい。\n"}, {ID: "2", Text: "かきを\n"}}},
+		{"corpus.jsonl", []BatchDocument{
+			{ID: "m", Text: "みかんはあまい。\n", Keywords: []string{"みあ"}}, {ID: "3", Text: "かきを\n"}}},
+	} {
+		got, err := ReadBatchDocuments(filepath.Join(dir, c.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tJSON(t, got) != tJSON(t, c.want) {
+			t.Errorf("%v: want %v, but returned %v", c.name, tJSON(t, c.want), tJSON(t, got))
+		}
+	}
+}
+
+func tJSON(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRunBatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acrostic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	o := tAnalyzerOptions()
+	o.Height = -1
+	o.Width = 4
+	o.MaxWidth = 5
+	o.Format = "jsonl"
+	i := &Instance{
+		Analyzer: &tAnalyzer{Output: map[string]string{"みかんはあまい。": tKnpMikan}},
+	}
+	docs := []BatchDocument{
+		{ID: "mikan", Text: "みかんはあまい。\n"},
+		{ID: "x/y", Text: "りんご\n"},
+	}
+	report, err := RunBatch(context.Background(), o, i, docs, [][]rune{[]rune("みあ")}, nil, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Documents) != 2 {
+		t.Fatalf("want 2 documents, but returned %v", report.Documents)
+	}
+	d := report.Documents[0]
+	if d.Status != "ok" || d.Results == 0 || d.File != "mikan.jsonl" {
+		t.Errorf("unexpected report: %+v", d)
+	}
+	if len(d.Combinations) != 2 || d.Combinations[0].Width != 4 || d.Combinations[1].Width != 5 {
+		t.Errorf("want widths 4 and 5, but returned %+v", d.Combinations)
+	}
+	if d := report.Documents[1]; d.Status != "error" || d.File != "" {
+		t.Errorf("want error for unknown text, but returned %+v", d)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "mikan.jsonl")); err != nil {
+		t.Error(err)
+	}
+	f, err := os.Open(filepath.Join(dir, "summary.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var summary BatchReport
+	err = json.NewDecoder(f).Decode(&summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tJSON(t, summary) != tJSON(t, report) {
+		t.Errorf("summary.json differs from the report: %v", tJSON(t, summary))
+	}
+	found := 0
+	for _, c := range summary.Combinations {
+		found += c.Results
+	}
+	if found == 0 || found > 2 {
+		t.Errorf("want 1 or 2 combinations found in one document, but returned %+v", summary.Combinations)
+	}
+}
@@ -0,0 +1,256 @@
+package acrostic
+
+import (
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	log "github.com/sirupsen/logrus"
+)
+
+// キャッシュの種類
+const (
+	// CacheKnp : JumanKnp.Execute(knp)
+	CacheKnp = "knp"
+	// CacheJuman : JumanKnp.Execute(juman)
+	CacheJuman = "juman"
+	// CacheKana : Kana.Get
+	CacheKana = "kana"
+	// CacheInflection : JumanKnp.Inflectionの活用型
+	CacheInflection = "inflection"
+	// CacheSynonyms : WordNet.GetSynonyms
+	CacheSynonyms = "synonyms"
+)
+
+// CacheKinds : キャッシュの種類の一覧
+func CacheKinds() []string {
+	return []string{CacheKnp, CacheJuman, CacheKana, CacheInflection, CacheSynonyms}
+}
+
+// Cache : 解析結果などをSQLiteに保存する永続キャッシュ
+// 入力文字列とツールのバージョンを表すフィンガープリントをキーにする．
+// ツールやデータベースを更新するとフィンガープリントが変わるので，古い結果は使われない．
+type Cache struct {
+	Options *Options
+	DB      *sql.DB
+	// Fingerprints : 種類 -> フィンガープリント
+	Fingerprints map[string]string
+
+	mutex  sync.Mutex
+	hits   map[string]int
+	misses map[string]int
+}
+
+// CacheStat : キャッシュの統計
+type CacheStat struct {
+	Kind string
+	// Entries : 現在のフィンガープリントの件数
+	Entries int
+	// Stale : 古いフィンガープリントの件数
+	Stale int
+	// Hits : この実行でヒットした数
+	Hits int
+	// Misses : この実行でヒットしなかった数
+	Misses int
+}
+
+// CacheDatabaseName : キャッシュのファイル名．未指定であればWordNetデータベースの隣に置く
+func CacheDatabaseName(o *Options) string {
+	if o.CacheDatabase != "" {
+		return o.CacheDatabase
+	}
+	return filepath.Join(filepath.Dir(o.WordNetDatabase), "acrostic-cache.db")
+}
+
+// NewCache : constructor
+func NewCache(o *Options) (*Cache, error) {
+	var err error
+	ret := new(Cache)
+	ret.Options = o
+	ret.hits = map[string]int{}
+	ret.misses = map[string]int{}
+	name := CacheDatabaseName(o)
+	ret.DB, err = sql.Open("sqlite3", name)
+	if err != nil {
+		return nil, err
+	}
+	_, err = ret.DB.Exec(`create table if not exists cache (
+		kind text not null,
+		fingerprint text not null,
+		key text not null,
+		value blob not null,
+		created integer not null,
+		primary key (kind, fingerprint, key))`)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize cache %v: %v", name, err.Error())
+	}
+	ret.Fingerprints = ret.fingerprints()
+	log.Debugf("cache: %v %v", name, ret.Fingerprints)
+	return ret, nil
+}
+
+// Close : データベースを閉じる
+func (c *Cache) Close() error {
+	if c == nil {
+		return nil
+	}
+	return c.DB.Close()
+}
+
+// Get : キャッシュを取得する．cがnilのときは常に見つからない
+func (c *Cache) Get(kind string, key string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	var v string
+	err := c.DB.QueryRow("select value from cache where kind=? and fingerprint=? and key=?",
+		kind, c.Fingerprints[kind], key).Scan(&v)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Warnf("cache: %v", err.Error())
+		}
+		c.misses[kind]++
+		return "", false
+	}
+	c.hits[kind]++
+	return v, true
+}
+
+// Set : キャッシュに保存する．失敗しても処理は続ける
+func (c *Cache) Set(kind string, key string, value string) {
+	if c == nil {
+		return
+	}
+	_, err := c.DB.Exec("insert or replace into cache values (?, ?, ?, ?, ?)",
+		kind, c.Fingerprints[kind], key, value, time.Now().Unix())
+	if err != nil {
+		log.Warnf("cache: %v", err.Error())
+	}
+}
+
+// Invalidate : キャッシュを削除する
+// kind: 種類(空文字列ならばすべて)
+// staleOnly: trueならば古いフィンガープリントのものだけ削除する
+// return: 削除した件数
+func (c *Cache) Invalidate(kind string, staleOnly bool) (int64, error) {
+	var total int64
+	kinds := CacheKinds()
+	if kind != "" {
+		kinds = []string{kind}
+	}
+	for _, k := range kinds {
+		var r sql.Result
+		var err error
+		if staleOnly {
+			r, err = c.DB.Exec("delete from cache where kind=? and fingerprint<>?",
+				k, c.Fingerprints[k])
+		} else {
+			r, err = c.DB.Exec("delete from cache where kind=?", k)
+		}
+		if err != nil {
+			return total, err
+		}
+		n, err := r.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	_, err := c.DB.Exec("vacuum")
+	return total, err
+}
+
+// Stats : 種類ごとの統計を取得する
+func (c *Cache) Stats() ([]CacheStat, error) {
+	ret := make([]CacheStat, 0)
+	for _, k := range CacheKinds() {
+		s := CacheStat{Kind: k}
+		err := c.DB.QueryRow("select count(*) from cache where kind=? and fingerprint=?",
+			k, c.Fingerprints[k]).Scan(&s.Entries)
+		if err != nil {
+			return nil, err
+		}
+		err = c.DB.QueryRow("select count(*) from cache where kind=? and fingerprint<>?",
+			k, c.Fingerprints[k]).Scan(&s.Stale)
+		if err != nil {
+			return nil, err
+		}
+		c.mutex.Lock()
+		s.Hits = c.hits[k]
+		s.Misses = c.misses[k]
+		c.mutex.Unlock()
+		ret = append(ret, s)
+	}
+	return ret, nil
+}
+
+// fingerprints : 結果に影響するコマンド，ファイル，オプションからフィンガープリントを作る
+func (c *Cache) fingerprints() map[string]string {
+	o := c.Options
+	juman := "juman:" + o.JumanCommand + toolStamp(o.JumanCommand)
+	knp := "knp:" + o.KnpCommand + toolStamp(o.KnpCommand)
+	inflection := juman + fileStamp(o.JumanDirectory+"/dic/JUMAN.katuyou")
+	kana := "kana:" + o.KanaMode
+	for _, m := range o.KanaModeOrder {
+		switch m {
+		case "juman":
+			kana += juman
+		case "mecab":
+			kana += o.MeCabCommand + toolStamp(o.MeCabCommand)
+		case "kakasi":
+			kana += o.KakasiCommand + toolStamp(o.KakasiCommand)
+		}
+	}
+	synonyms := fmt.Sprintf("wordnet:%v%v:%v:%v:%v",
+		o.WordNetDatabase, fileStamp(o.WordNetDatabase),
+		o.SynonymsJapaneseOnly, o.UsePolite, inflection+kana)
+	return map[string]string{
+		CacheJuman:      hashString(juman),
+		CacheKnp:        hashString(juman + knp),
+		CacheInflection: hashString(inflection),
+		CacheKana:       hashString(kana),
+		CacheSynonyms:   hashString(synonyms),
+	}
+}
+
+// toolStamp : コマンドの実行ファイルの場所，大きさ，更新日時
+func toolStamp(command string) string {
+	stamp := ""
+	for _, pipe := range strings.Split(command, "|") {
+		f := strings.Fields(pipe)
+		if len(f) == 0 {
+			continue
+		}
+		path, err := exec.LookPath(f[0])
+		if err != nil {
+			stamp += "(" + f[0] + ")"
+			continue
+		}
+		stamp += fileStamp(path)
+	}
+	return stamp
+}
+
+// fileStamp : ファイルの場所，大きさ，更新日時
+func fileStamp(filename string) string {
+	st, err := os.Stat(filename)
+	if err != nil {
+		return "(" + filename + ")"
+	}
+	return fmt.Sprintf("(%v %v %v)", filename, st.Size(), st.ModTime().Unix())
+}
+
+func hashString(s string) string {
+	h := sha1.Sum([]byte(s))
+	return hex.EncodeToString(h[:])
+}
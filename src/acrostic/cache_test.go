@@ -0,0 +1,106 @@
+package acrostic
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	// nilのキャッシュは何もしない
+	var nc *Cache
+	if _, ok := nc.Get(CacheKnp, "a"); ok {
+		t.Errorf("nil cache should not hit")
+	}
+	nc.Set(CacheKnp, "a", "b")
+
+	dir, err := ioutil.TempDir("", "acrostic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	o := tAnalyzerOptions()
+	o.CacheDatabase = filepath.Join(dir, "cache.db")
+	c, err := NewCache(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, ok := c.Get(CacheKnp, "みかん"); ok {
+		t.Errorf("empty cache should not hit")
+	}
+	c.Set(CacheKnp, "みかん", tKnpMikan)
+	c.Set(CacheKana, "柿", "かき")
+	if v, ok := c.Get(CacheKnp, "みかん"); !ok || v != tKnpMikan {
+		t.Errorf("want cached knp output, but returned %v %v", ok, v)
+	}
+	if _, ok := c.Get(CacheJuman, "みかん"); ok {
+		t.Errorf("kinds should be separated")
+	}
+
+	// フィンガープリントが変わったものは古いとみなす
+	c.Fingerprints[CacheKana] = "old"
+	c.Set(CacheKana, "梨", "なし")
+	c.Fingerprints[CacheKana] = hashString("new")
+	stats, err := c.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range stats {
+		switch s.Kind {
+		case CacheKnp:
+			if s.Entries != 1 || s.Hits != 1 || s.Misses != 1 {
+				t.Errorf("unexpected knp stat: %+v", s)
+			}
+		case CacheKana:
+			if s.Entries != 0 || s.Stale != 2 {
+				t.Errorf("unexpected kana stat: %+v", s)
+			}
+		}
+	}
+	n, err := c.Invalidate("", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("want 2 stale entries removed, but removed %v", n)
+	}
+	n, err = c.Invalidate(CacheKnp, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("want 1 entry removed, but removed %v", n)
+	}
+	if _, ok := c.Get(CacheKnp, "みかん"); ok {
+		t.Errorf("invalidated entry should not hit")
+	}
+}
+
+func TestJumanKnpExecuteCache(t *testing.T) {
+	// コマンドが動いていなくても，キャッシュにある出力を返す
+	dir, err := ioutil.TempDir("", "acrostic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	o := tAnalyzerOptions()
+	o.CacheDatabase = filepath.Join(dir, "cache.db")
+	c, err := NewCache(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Set(CacheKnp, "みかんはあまい。", tKnpMikan)
+
+	jk := &JumanKnp{Options: o, Instance: &Instance{Cache: c}}
+	r, err := jk.Execute([]rune("みかんはあまい。"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(r) != tKnpMikan {
+		t.Errorf("want cached knp output, but returned %v", string(r))
+	}
+}
@@ -0,0 +1,254 @@
+package acrostic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// Checkpoint : 長い探索を途中から再開するためのチェックポイント(--checkpoint, --resume)
+// 探索を終えて書き出した位置を，キーワード，幅，文章，文パターン(bpai)，
+// 最初の基本句のパターン(PatternStackの先頭)の順に覚えておく
+type Checkpoint struct {
+	// OptionsHash : 探索の結果を変えるオプション，テキスト，キーワードのハッシュ
+	OptionsHash string `json:"options_hash"`
+	// Keyword : 探索中のキーワードの番号
+	Keyword int `json:"keyword"`
+	// Width : 探索中の行の幅
+	Width int `json:"width"`
+	// Paragraph : 探索中の文章の番号
+	Paragraph int `json:"paragraph"`
+	// Pattern : 探索中の文パターンの番号(bpai)．これより前の文パターンは書き出した
+	Pattern int `json:"pattern"`
+	// Done : Patternの文パターンで，探索を終えて結果を書き出した最初の基本句のパターン
+	Done []int `json:"done"`
+	// Finished : すべての探索を終えたかどうか
+	Finished bool `json:"finished"`
+	// UpdatedAt : 書き出した時刻
+	UpdatedAt time.Time `json:"updated_at"`
+
+	fileName string
+	interval time.Duration
+	saved    time.Time
+	// resume : 再開する位置(nilならば最初から)
+	resume *Checkpoint
+	mutex  sync.Mutex
+}
+
+// NewCheckpoint : constructor
+// Options.Checkpoint, Options.Resumeのどちらもなければnilを返す．
+// Options.Resumeがあれば読み込み，ハッシュが違えばErrCheckpointMismatchを返す
+func NewCheckpoint(o *Options, text [][]rune, keywords [][]rune) (*Checkpoint, error) {
+	if o.Checkpoint == "" && o.Resume == "" {
+		return nil, nil
+	}
+	hash, err := checkpointHash(o, text, keywords)
+	if err != nil {
+		return nil, err
+	}
+	ret := &Checkpoint{
+		OptionsHash: hash,
+		Done:        []int{},
+		fileName:    o.Checkpoint,
+		interval:    o.CheckpointInterval,
+	}
+	if ret.fileName == "" {
+		ret.fileName = o.Resume
+	}
+	if o.Resume == "" {
+		return ret, nil
+	}
+	b, err := ioutil.ReadFile(o.Resume)
+	if err != nil {
+		return nil, err
+	}
+	r := new(Checkpoint)
+	err = json.Unmarshal(b, r)
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint: %v: %w", o.Resume, err)
+	}
+	if r.OptionsHash != hash {
+		return nil, fmt.Errorf("%w: %v", ErrCheckpointMismatch, o.Resume)
+	}
+	if r.Finished {
+		return nil, fmt.Errorf("checkpoint: search already finished: %v", o.Resume)
+	}
+	ret.resume = r
+	return ret, nil
+}
+
+// checkpointHash : 探索の結果を変えるオプション，テキスト，キーワードのハッシュ
+// 速さ，ログ，時間制限だけに関わるオプションは除く
+func checkpointHash(o *Options, text [][]rune, keywords [][]rune) (string, error) {
+	c := *o
+	c.Checkpoint = ""
+	c.CheckpointInterval = 0
+	c.Resume = ""
+	c.Parallel = false
+	c.MemoSize = 0
+	c.GCHeapSize = 0
+	c.WipeOut = false
+	c.WipeOutLength = 0
+	c.Progress = false
+	c.ProgressJSON = ""
+	c.Verbose = false
+	c.Verbosely = false
+	c.Quiet = false
+	c.Silent = false
+	c.Confirm = false
+	c.Interactive = false
+	c.UsePproof = false
+	c.Timeout = 0
+	c.DeadlineString = ""
+	c.Deadline = time.Time{}
+	c.Cache = false
+	c.CacheDatabase = ""
+	c.AnalyzerWorkers = 0
+	c.AnalyzerTimeout = 0
+	c.Language = ""
+	b, err := json.Marshal(struct {
+		Options  Options
+		Text     []string
+		Keywords []string
+	}{c, runesToStrings(text), runesToStrings(keywords)})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// runesToStrings : [][]runeを[]stringにする
+func runesToStrings(in [][]rune) []string {
+	ret := make([]string, len(in))
+	for i := range in {
+		ret[i] = string(in[i])
+	}
+	return ret
+}
+
+// checkpointBefore : 位置aがbより前かどうか
+func checkpointBefore(a []int, b []int) bool {
+	return compareStack(a, b) < 0
+}
+
+// SkipGenerate : --resumeで，k番目のキーワード，幅w，p番目の文章の探索をすでに終えたかどうか
+func (c *Checkpoint) SkipGenerate(k int, w int, p int) bool {
+	if c == nil || c.resume == nil {
+		return false
+	}
+	r := c.resume
+	return checkpointBefore([]int{k, w, p}, []int{r.Keyword, r.Width, r.Paragraph})
+}
+
+// StartGenerate : k番目のキーワード，幅w，p番目の文章の探索を始める
+func (c *Checkpoint) StartGenerate(k int, w int, p int) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	c.Keyword = k
+	c.Width = w
+	c.Paragraph = p
+	c.Pattern = 0
+	c.Done = []int{}
+	c.mutex.Unlock()
+}
+
+// resuming : 再開する位置と同じキーワード，幅，文章を探索しているかどうか
+func (c *Checkpoint) resuming() bool {
+	r := c.resume
+	return r != nil && r.Keyword == c.Keyword && r.Width == c.Width && r.Paragraph == c.Paragraph
+}
+
+// SkipPattern : --resumeで，bpai番目の文パターンの探索をすでに終えたかどうか
+func (c *Checkpoint) SkipPattern(bpai int) bool {
+	if c == nil {
+		return false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.resuming() && bpai < c.resume.Pattern
+}
+
+// StartPattern : bpai番目の文パターンの探索を始め，期限が来ていればチェックポイントを書き出す
+// return: --resumeで，探索を終えていた最初の基本句のパターン
+func (c *Checkpoint) StartPattern(bpai int) ([]int, error) {
+	if c == nil {
+		return nil, nil
+	}
+	c.mutex.Lock()
+	c.Pattern = bpai
+	c.Done = []int{}
+	if c.resuming() && bpai == c.resume.Pattern {
+		c.Done = append(c.Done, c.resume.Done...)
+	}
+	done := append([]int{}, c.Done...)
+	c.mutex.Unlock()
+	return done, c.saveIfDue()
+}
+
+// FinishTop : 最初の基本句のパターンpiの探索を終えて結果を書き出したことを覚え，
+// 期限が来ていればチェックポイントを書き出す
+func (c *Checkpoint) FinishTop(pi int) error {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	c.Done = append(c.Done, pi)
+	c.mutex.Unlock()
+	return c.saveIfDue()
+}
+
+// Finish : すべての探索を終えたことを書き出す
+func (c *Checkpoint) Finish() error {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	c.Finished = true
+	c.mutex.Unlock()
+	return c.Save()
+}
+
+// saveIfDue : 前に書き出してからintervalが過ぎていれば書き出す
+func (c *Checkpoint) saveIfDue() error {
+	c.mutex.Lock()
+	due := time.Since(c.saved) >= c.interval
+	c.mutex.Unlock()
+	if due == false {
+		return nil
+	}
+	return c.Save()
+}
+
+// Save : チェックポイントを書き出す
+// 書き出している途中で止まっても前のチェックポイントが残るように，一時ファイルから置き換える
+func (c *Checkpoint) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.UpdatedAt = time.Now()
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := c.fileName + ".tmp"
+	err = ioutil.WriteFile(tmp, append(b, '\n'), 0644)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, c.fileName)
+	if err != nil {
+		return err
+	}
+	c.saved = c.UpdatedAt
+	return nil
+}
@@ -0,0 +1,116 @@
+package acrostic
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func tCheckpointOptions(t *testing.T) *Options {
+	dir, err := ioutil.TempDir("", "acrostic-checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return &Options{Width: 3, Checkpoint: filepath.Join(dir, "checkpoint.json")}
+}
+
+func TestCheckpoint(t *testing.T) {
+	o := tCheckpointOptions(t)
+	text := [][]rune{[]rune("あみいうかえ")}
+	keywords := [][]rune{[]rune("みか")}
+	c, err := NewCheckpoint(o, text, keywords)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.StartGenerate(0, 3, 0)
+	if _, err = c.StartPattern(2); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.FinishTop(1); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	o.Resume = o.Checkpoint
+	r, err := NewCheckpoint(o, text, keywords)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.SkipGenerate(0, 3, 0) || r.SkipGenerate(0, 4, 0) || r.SkipGenerate(1, 3, 0) {
+		t.Errorf("should not skip the current or later positions")
+	}
+	if r.SkipGenerate(0, 2, 5) == false {
+		t.Errorf("should skip narrower width")
+	}
+	r.StartGenerate(0, 3, 0)
+	if r.SkipPattern(1) == false || r.SkipPattern(2) {
+		t.Errorf("want skipped only before pattern 2")
+	}
+	done, err := r.StartPattern(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(done) != "[1]" {
+		t.Errorf("want done [1], but returned %v", done)
+	}
+	if done, _ = r.StartPattern(3); len(done) != 0 {
+		t.Errorf("want nothing done in pattern 3, but returned %v", done)
+	}
+
+	// 違うオプションでは再開できない
+	o.Width = 4
+	_, err = NewCheckpoint(o, text, keywords)
+	if !errors.Is(err, ErrCheckpointMismatch) {
+		t.Errorf("want ErrCheckpointMismatch, but returned %v", err)
+	}
+	// 速さだけに関わるオプションは変えてもよい
+	o.Width = 3
+	o.Parallel = true
+	o.Timeout = time.Hour
+	if _, err = NewCheckpoint(o, text, keywords); err != nil {
+		t.Errorf("want resumed, but returned %v", err)
+	}
+
+	if err = r.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewCheckpoint(o, text, keywords); err == nil {
+		t.Errorf("want error for a finished checkpoint")
+	}
+}
+
+func TestArrangeMatrixSearchCheckpoint(t *testing.T) {
+	want := tSearchPatterns(t, func(*ArrangeMatrix) {})
+	o := tCheckpointOptions(t)
+	c, err := NewCheckpoint(o, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 最初の基本句のパターンごとに結果を書き出して，探索を終えたことを覚える
+	found := make([]string, 0)
+	got := tSearchPatterns(t, func(am *ArrangeMatrix) {
+		am.Checkpoint = c
+		am.Writer.Handler = func(r Result) error {
+			found = append(found, fmt.Sprint(r.PatternStack))
+			return nil
+		}
+	})
+	if len(got) != 0 {
+		t.Errorf("want all results written, but %v left", got)
+	}
+	sort.Strings(found)
+	if fmt.Sprint(found) != fmt.Sprint(want) {
+		t.Errorf("want %v, but returned %v", want, found)
+	}
+	if fmt.Sprint(c.Done) != "[0 1 2]" {
+		t.Errorf("want done [0 1 2], but returned %v", c.Done)
+	}
+}
@@ -0,0 +1,252 @@
+package acrostic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	flag "github.com/ogier/pflag"
+	log "github.com/sirupsen/logrus"
+)
+
+// ExecuteCommand : サブコマンドを実行する
+// Options.Commandが空文字列のときは，通常の縦読み化をするので，呼び出してはならない．
+func ExecuteCommand(o *Options) error {
+	switch o.Command {
+	case "cache":
+		return commandCache(o, o.Args)
+	case "serve":
+		return commandServe(o)
+	case "merge":
+		return commandMerge(o, o.Args)
+	case "batch":
+		return commandBatch(o, o.Args)
+	case "eval":
+		return commandEval(o, o.Args)
+	}
+	return fmt.Errorf("unknown command: %v", o.Command)
+}
+
+// commandCache : キャッシュを操作する
+// cache stats
+// cache invalidate [kind]
+// cache prune
+func commandCache(o *Options, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: cache stats|invalidate [kind]|prune")
+	}
+	c, err := NewCache(o)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	switch args[0] {
+	case "stats":
+		stats, err := c.Stats()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%v\n", CacheDatabaseName(o))
+		fmt.Printf("%-12v %10v %10v\n", "kind", "entries", "stale")
+		for _, s := range stats {
+			fmt.Printf("%-12v %10v %10v\n", s.Kind, s.Entries, s.Stale)
+		}
+		return nil
+	case "invalidate":
+		kind := ""
+		if len(args) >= 2 {
+			kind = args[1]
+			found := false
+			for _, k := range CacheKinds() {
+				if k == kind {
+					found = true
+					break
+				}
+			}
+			if found == false {
+				return fmt.Errorf("unknown cache kind: %v (%v)", kind, CacheKinds())
+			}
+		}
+		n, err := c.Invalidate(kind, false)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%v entries removed\n", n)
+		return nil
+	case "prune":
+		n, err := c.Invalidate("", true)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%v stale entries removed\n", n)
+		return nil
+	}
+	return fmt.Errorf("unknown cache command: %v", args[0])
+}
+
+// commandServe : HTTPサーバを起動する
+// [--listen addr] [--max-jobs n] serve
+func commandServe(o *Options) error {
+	s, err := NewServer(o, nil)
+	if err != nil {
+		return err
+	}
+	return s.ListenAndServe()
+}
+
+// commandMerge : --shardで分けて探索した結果(--format json, jsonl)をまとめる
+// [--format json|jsonl|html|svg] [--sort score] [--top n] [-o file] merge file...
+func commandMerge(o *Options, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: merge file...")
+	}
+	lists := make([][]ResultRecord, len(args))
+	for i, name := range args {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		lists[i], err = ReadResultRecords(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("merge: %v: %w", name, err)
+		}
+	}
+	records, err := MergeResultRecords(lists...)
+	if err != nil {
+		return err
+	}
+	results := make([]Result, len(records))
+	for i := range records {
+		results[i] = NewResultFromRecord(records[i])
+	}
+	log.Infof("merge: %v results from %v files", len(results), len(args))
+
+	w := os.Stdout
+	if o.OutFileName != "" {
+		f, err := os.Create(o.OutFileName)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return WriteResults(w, o, results)
+}
+
+// commandBatch : 複数の文書を，同じInstanceでひとつずつ縦読み化する
+// -k file -o dir [--format json|jsonl|html|svg|aozora|latex] batch dir|file.jsonl|file
+func commandBatch(o *Options, args []string) error {
+	if len(args) != 1 || o.OutFileName == "" {
+		return errors.New("usage: -o dir batch dir|file.jsonl|file")
+	}
+	docs, err := ReadBatchDocuments(args[0])
+	if err != nil {
+		return err
+	}
+	v := &Acrostic{Options: o}
+	if o.KeywordFileName != "" {
+		err = v.ReadKeyword()
+		if err != nil {
+			return err
+		}
+	}
+	for i := range docs {
+		if len(docs[i].Keywords) == 0 && len(v.Keywords) == 0 {
+			return fmt.Errorf("batch: %v: require keyword (-k)", docs[i].ID)
+		}
+	}
+	instance, err := NewInstance(o)
+	if err != nil {
+		return err
+	}
+	report, err := RunBatch(context.Background(), o, instance, docs, v.Keywords, v.KeywordColumns, o.OutFileName)
+	if err != nil {
+		return err
+	}
+	ok := 0
+	for _, d := range report.Documents {
+		if d.Results > 0 {
+			ok++
+		}
+	}
+	fmt.Printf("%v/%v documents have results\n", ok, len(report.Documents))
+	fmt.Printf("%-20v %6v %10v\n", "keyword", "width", "documents")
+	for _, c := range report.Combinations {
+		fmt.Printf("%-20v %6v %10v\n", c.Keyword, c.Width, c.Results)
+	}
+	return nil
+}
+
+// commandEval : 文，キーワード，オプションの組み合わせごとに縦読み化して，見つかる割合を測る
+// -k file [--grid name=v1,v2;...] [--timeout d] [-o file.csv] eval dir|file.jsonl|file
+// 指定しなければ--oneと--timeout 1mで1件ずつ評価する
+func commandEval(o *Options, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: eval dir|file.jsonl|file")
+	}
+	docs, err := ReadEvalCorpus(args[0])
+	if err != nil {
+		return err
+	}
+	v := &Acrostic{Options: o}
+	if o.KeywordFileName != "" {
+		err = v.ReadKeyword()
+		if err != nil {
+			return err
+		}
+	}
+	for i := range docs {
+		if len(docs[i].Keywords) == 0 && len(v.Keywords) == 0 {
+			return fmt.Errorf("eval: %v: require keyword (-k)", docs[i].ID)
+		}
+	}
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	if set["one"] == false {
+		o.One = true
+	}
+	if set["timeout"] == false {
+		o.Timeout = time.Minute
+	}
+	axes, err := ParseEvalGrid(o.EvalGrid)
+	if err != nil {
+		return err
+	}
+	points, err := EvalPoints(o, axes, flag.Set, func(name string) (string, error) {
+		f := flag.Lookup(name)
+		if f == nil {
+			return "", fmt.Errorf("grid: unknown option: %v", name)
+		}
+		return f.Value.String(), nil
+	})
+	if err != nil {
+		return err
+	}
+	instance, err := NewInstance(o)
+	if err != nil {
+		return err
+	}
+	// CSVを-oに書き出すときは集計を標準出力へ，CSVを標準出力に書き出すときは標準エラー出力へ
+	var w io.Writer = os.Stdout
+	var summary io.Writer = os.Stderr
+	if o.OutFileName != "" {
+		f, err := os.Create(o.OutFileName)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+		summary = os.Stdout
+	}
+	cases, err := RunEval(context.Background(), instance, docs, v.Keywords, points, w)
+	if err != nil {
+		return err
+	}
+	return WriteEvalSummary(summary, SummarizeEval(cases))
+}
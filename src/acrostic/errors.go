@@ -0,0 +1,24 @@
+package acrostic
+
+import "errors"
+
+// ライブラリが返すエラー
+// 呼び出し側はerrors.Isで判別して，その入力をスキップして処理を続けることができる．
+var (
+	// ErrEmptyInput : 解析ツールに空の文を渡した
+	ErrEmptyInput = errors.New("empty input")
+	// ErrKeywordNotFound : どのキーワードも作成した文中に現れない
+	ErrKeywordNotFound = errors.New("all keywords not found")
+	// ErrUnknownPart : 扱えない品詞が現れた
+	ErrUnknownPart = errors.New("unknown part")
+	// ErrUnknownWordNetLink : WordNetのリンクの名前が不明
+	ErrUnknownWordNetLink = errors.New("unknown wordnet link")
+	// ErrOutOfRange : 縦読み行列の範囲外に文字を置こうとした
+	ErrOutOfRange = errors.New("out of range")
+	// ErrToolTimeout : 外部コマンド(juman, knp, mecab, kakasi)が制限時間内に応答しなかった
+	ErrToolTimeout = errors.New("tool timeout")
+	// ErrToolCrashed : 外部コマンドが異常終了した
+	ErrToolCrashed = errors.New("tool crashed")
+	// ErrCheckpointMismatch : 再開しようとしたチェックポイントが，違うオプション，テキスト，キーワードで作られた
+	ErrCheckpointMismatch = errors.New("checkpoint mismatch")
+)
@@ -0,0 +1,73 @@
+package acrostic
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestErrKeywordNotFound(t *testing.T) {
+	o := tAnalyzerOptions()
+	o.Silent = true
+	v := &Acrostic{
+		Options: o,
+		Instance: &Instance{
+			Analyzer: &tAnalyzer{Output: map[string]string{"みかんはあまい。": tKnpMikan}},
+		},
+		Text:     [][]rune{[]rune("みかんはあまい。\n")},
+		Keywords: [][]rune{[]rune("りんご")},
+	}
+	err := v.Analyze()
+	if !errors.Is(err, ErrKeywordNotFound) {
+		t.Errorf("want ErrKeywordNotFound, but returned %v", err)
+	}
+}
+
+func TestErrOutOfRange(t *testing.T) {
+	// 8文字の文は，幅4の1行に収まらない
+	o := tAnalyzerOptions()
+	o.Width = 4
+	o.Height = 1
+	_, err := Run(context.Background(), Request{
+		Options: o,
+		Instance: &Instance{
+			Analyzer: &tAnalyzer{Output: map[string]string{"みかんはあまい。": tKnpMikan}},
+		},
+		Text:     []rune("みかんはあまい。"),
+		Keywords: [][]rune{[]rune("みあ")},
+	})
+	if !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("want ErrOutOfRange, but returned %v", err)
+	}
+}
+
+func TestErrUnknownPart(t *testing.T) {
+	bp := &BasicPhrase{
+		Options:            tAnalyzerOptions(),
+		SurfaceOrder:       []Part{NounPart, UnknownPart},
+		IndependentSurface: [][]rune{[]rune("みかん")},
+		PatternLengthMap:   map[int]bool{},
+	}
+	err := bp.AppendPattern([]rune("みかん"), PatternSource{}, true, false)
+	if !errors.Is(err, ErrUnknownPart) {
+		t.Errorf("want ErrUnknownPart, but returned %v", err)
+	}
+	err = bp.UpdateSurface()
+	if !errors.Is(err, ErrUnknownPart) {
+		t.Errorf("want ErrUnknownPart, but returned %v", err)
+	}
+}
+
+func TestErrUnknownWordNetLink(t *testing.T) {
+	l, err := NewWordNetLink("hype")
+	if err != nil || l != WNHype {
+		t.Errorf("want WNHype, but returned %v %v", l, err)
+	}
+	_, err = NewWordNetLink("antonym")
+	if !errors.Is(err, ErrUnknownWordNetLink) {
+		t.Errorf("want ErrUnknownWordNetLink, but returned %v", err)
+	}
+	if s := WordNetAnswer(100).String(); s == "" {
+		t.Errorf("want fallback string for unknown WordNetAnswer")
+	}
+}
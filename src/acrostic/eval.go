@@ -0,0 +1,307 @@
+package acrostic
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EvalAxis : --gridの軸ひとつ．オプション(長い名前)と，試す値
+type EvalAxis struct {
+	Name   string
+	Values []string
+}
+
+// EvalPoint : 試すオプションの組み合わせひとつ
+type EvalPoint struct {
+	// Label : 組み合わせの名前(name=value をカンマで区切る．空ならば元のオプション)
+	Label string
+	// Options : この組み合わせのオプション
+	Options *Options
+}
+
+// EvalCase : 文ひとつ，キーワードひとつ，オプションの組み合わせひとつの評価
+type EvalCase struct {
+	// Sentence : 文の名前
+	Sentence string
+	// Keyword : キーワード
+	Keyword string
+	// Options : オプションの組み合わせの名前(EvalPoint.Label)
+	Options string
+	// Status : ok, not-found, truncated, error
+	Status string
+	// Results : 見つかった結果の数
+	Results int
+	// FirstWidth : 結果が見つかった最小の幅(見つからなければ0)
+	FirstWidth int
+	// Nodes : 探索した状態の数
+	Nodes int64
+	// Elapsed : かかった時間
+	Elapsed time.Duration
+}
+
+// EvalSummary : キーワードとオプションの組み合わせごとの集計
+type EvalSummary struct {
+	Keyword string
+	Options string
+	// Cases : 文の数
+	Cases int
+	// Successes : 結果が見つかった文の数
+	Successes int
+	// Truncated : 時間切れになった文の数
+	Truncated int
+	// Errors : 失敗した文の数
+	Errors int
+	// FirstWidth : 見つかった文のFirstWidthの平均
+	FirstWidth float64
+	// Nodes : 探索した状態の数の平均
+	Nodes float64
+	// Elapsed : かかった時間の平均
+	Elapsed time.Duration
+}
+
+// Rate : 結果が見つかった文の割合(%)
+func (s EvalSummary) Rate() float64 {
+	if s.Cases == 0 {
+		return 0
+	}
+	return float64(s.Successes) * 100 / float64(s.Cases)
+}
+
+// ParseEvalGrid : --gridを読み取る．「name=v1,v2;name=v1,v2」の形で，軸を;で，値を,で区切る
+func ParseEvalGrid(s string) ([]EvalAxis, error) {
+	ret := make([]EvalAxis, 0)
+	for _, a := range strings.Split(s, ";") {
+		if strings.TrimSpace(a) == "" {
+			continue
+		}
+		kv := strings.SplitN(a, "=", 2)
+		name := strings.TrimSpace(kv[0])
+		if len(kv) != 2 || name == "" || strings.TrimSpace(kv[1]) == "" {
+			return nil, fmt.Errorf("grid: want name=value,...: %v", a)
+		}
+		axis := EvalAxis{Name: name}
+		for _, v := range strings.Split(kv[1], ",") {
+			axis.Values = append(axis.Values, strings.TrimSpace(v))
+		}
+		ret = append(ret, axis)
+	}
+	return ret, nil
+}
+
+// EvalPoints : 軸の値のすべての組み合わせについて，oをコピーしたオプションを作る
+// set: オプションに値を書き込む関数(コマンドラインのフラグと同じ名前と値)．
+// get: 今の値を返す関数．作り終えたらoを元の値に戻す
+func EvalPoints(o *Options, axes []EvalAxis,
+	set func(name string, value string) error, get func(name string) (string, error)) ([]EvalPoint, error) {
+	old := make([]string, len(axes))
+	for i := range axes {
+		v, err := get(axes[i].Name)
+		if err != nil {
+			return nil, err
+		}
+		old[i] = v
+	}
+	ret := make([]EvalPoint, 0)
+	index := make([]int, len(axes))
+	for {
+		label := make([]string, len(axes))
+		for i := range axes {
+			label[i] = axes[i].Name + "=" + axes[i].Values[index[i]]
+			err := set(axes[i].Name, axes[i].Values[index[i]])
+			if err != nil {
+				return nil, fmt.Errorf("grid: %v: %w", label[i], err)
+			}
+		}
+		c := *o
+		ret = append(ret, EvalPoint{Label: strings.Join(label, ","), Options: &c})
+		// 最後の軸から順に進める
+		i := len(axes) - 1
+		for ; i >= 0; i-- {
+			index[i]++
+			if index[i] < len(axes[i].Values) {
+				break
+			}
+			index[i] = 0
+		}
+		if i < 0 {
+			break
+		}
+	}
+	for i := range axes {
+		err := set(axes[i].Name, old[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// ReadEvalCorpus : evalの文を読み取る
+// ディレクトリと.jsonlはReadBatchDocumentsと同じ．それ以外は空でない1行をひとつの文とし，1から番号を振る
+func ReadEvalCorpus(name string) ([]BatchDocument, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() || filepath.Ext(name) == ".jsonl" {
+		return ReadBatchDocuments(name)
+	}
+	lines := make([][]rune, 0)
+	err = readFileA2(name, &lines)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]BatchDocument, len(lines))
+	for i := range lines {
+		ret[i] = BatchDocument{ID: strconv.Itoa(i + 1), Text: string(lines[i]) + "\n"}
+	}
+	return ret, nil
+}
+
+// RunEval : 文，キーワード，オプションの組み合わせごとに縦読み化し，1件ごとにwへCSVで書き出す
+// 時間の上限は組み合わせごとのOptions.Timeoutにしたがう．ctxが終了したときは残りを評価しない
+func RunEval(ctx context.Context, instance *Instance, docs []BatchDocument, keywords [][]rune,
+	points []EvalPoint, w io.Writer) ([]EvalCase, error) {
+	c := csv.NewWriter(w)
+	err := c.Write([]string{
+		"sentence", "keyword", "options", "status", "success", "first_width", "results", "nodes", "elapsed"})
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]EvalCase, 0)
+	for _, d := range docs {
+		kws := keywords
+		if len(d.Keywords) > 0 {
+			kws = make([][]rune, len(d.Keywords))
+			for i := range d.Keywords {
+				kws[i] = []rune(d.Keywords[i])
+			}
+		}
+		for _, k := range kws {
+			for _, p := range points {
+				if ctx.Err() != nil {
+					c.Flush()
+					return ret, c.Error()
+				}
+				e := runEvalCase(ctx, instance, d, k, p)
+				ret = append(ret, e)
+				first := ""
+				if e.FirstWidth > 0 {
+					first = strconv.Itoa(e.FirstWidth)
+				}
+				err = c.Write([]string{
+					e.Sentence, e.Keyword, e.Options, e.Status, strconv.FormatBool(e.Results > 0), first,
+					strconv.Itoa(e.Results), strconv.FormatInt(e.Nodes, 10),
+					strconv.FormatFloat(e.Elapsed.Seconds(), 'f', 3, 64)})
+				if err != nil {
+					return ret, err
+				}
+				// 途中で止めても，それまでの結果が残るようにする
+				c.Flush()
+			}
+		}
+	}
+	c.Flush()
+	return ret, c.Error()
+}
+
+// runEvalCase : 1件を評価する
+func runEvalCase(ctx context.Context, instance *Instance, d BatchDocument, k []rune, p EvalPoint) EvalCase {
+	stats := new(RunStats)
+	start := time.Now()
+	results, err := Run(ctx, Request{
+		Options:  p.Options,
+		Instance: instance,
+		Text:     []rune(d.Text),
+		Keywords: [][]rune{k},
+		Stats:    stats,
+	})
+	ret := EvalCase{
+		Sentence: d.ID,
+		Keyword:  string(k),
+		Options:  p.Label,
+		Status:   runStatus(err, len(results)),
+		Results:  len(results),
+		Nodes:    stats.Nodes,
+		Elapsed:  time.Since(start),
+	}
+	for _, r := range results {
+		if ret.FirstWidth == 0 || r.Width < ret.FirstWidth {
+			ret.FirstWidth = r.Width
+		}
+	}
+	return ret
+}
+
+// SummarizeEval : キーワードとオプションの組み合わせごとに集計する
+func SummarizeEval(cases []EvalCase) []EvalSummary {
+	index := map[string]int{}
+	ret := make([]EvalSummary, 0)
+	widths := make([]int, 0)
+	for _, c := range cases {
+		key := c.Keyword + "\n" + c.Options
+		i, ok := index[key]
+		if ok == false {
+			i = len(ret)
+			index[key] = i
+			ret = append(ret, EvalSummary{Keyword: c.Keyword, Options: c.Options})
+			widths = append(widths, 0)
+		}
+		s := &ret[i]
+		s.Cases++
+		switch c.Status {
+		case "truncated":
+			s.Truncated++
+		case "error":
+			s.Errors++
+		}
+		if c.Results > 0 {
+			s.Successes++
+			widths[i] += c.FirstWidth
+		}
+		s.Nodes += float64(c.Nodes)
+		s.Elapsed += c.Elapsed
+	}
+	for i := range ret {
+		s := &ret[i]
+		if s.Successes > 0 {
+			s.FirstWidth = float64(widths[i]) / float64(s.Successes)
+		}
+		s.Nodes /= float64(s.Cases)
+		s.Elapsed /= time.Duration(s.Cases)
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Keyword < ret[j].Keyword
+	})
+	return ret
+}
+
+// WriteEvalSummary : 集計を表にして書き出す
+func WriteEvalSummary(w io.Writer, summary []EvalSummary) error {
+	_, err := fmt.Fprintf(w, "%-12v %-30v %6v %6v %8v %6v %6v %8v %12v %10v\n",
+		"keyword", "options", "cases", "found", "rate", "trunc", "error", "width", "nodes", "elapsed")
+	if err != nil {
+		return err
+	}
+	for _, s := range summary {
+		options := s.Options
+		if options == "" {
+			options = "-"
+		}
+		_, err = fmt.Fprintf(w, "%-12v %-30v %6v %6v %7.1f%% %6v %6v %8.1f %12.0f %9.3fs\n",
+			s.Keyword, options, s.Cases, s.Successes, s.Rate(), s.Truncated, s.Errors,
+			s.FirstWidth, s.Nodes, s.Elapsed.Seconds())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package acrostic
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func TestParseEvalGrid(t *testing.T) {
+	axes, err := ParseEvalGrid("synonyms=true,false; pattern-size = 100,1000;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"Name":"synonyms","Values":["true","false"]},{"Name":"pattern-size","Values":["100","1000"]}]`
+	if got := tJSON(t, axes); got != want {
+		t.Errorf("want %v, but returned %v", want, got)
+	}
+	if axes, err = ParseEvalGrid(""); err != nil || len(axes) != 0 {
+		t.Errorf("want no axes, but returned %v, %v", axes, err)
+	}
+	for _, s := range []string{"synonyms", "=true", "synonyms="} {
+		if _, err = ParseEvalGrid(s); err == nil {
+			t.Errorf("%q: want error", s)
+		}
+	}
+}
+
+func TestEvalPoints(t *testing.T) {
+	o := &Options{Width: 3, One: true}
+	set := func(name string, value string) error {
+		switch name {
+		case "width":
+			n, err := strconv.Atoi(value)
+			o.Width = n
+			return err
+		case "one":
+			o.One = value == "true"
+			return nil
+		}
+		return fmt.Errorf("unknown option: %v", name)
+	}
+	get := func(name string) (string, error) {
+		switch name {
+		case "width":
+			return strconv.Itoa(o.Width), nil
+		case "one":
+			return strconv.FormatBool(o.One), nil
+		}
+		return "", fmt.Errorf("unknown option: %v", name)
+	}
+	points, err := EvalPoints(o, []EvalAxis{{"one", []string{"true", "false"}}, {"width", []string{"4", "5"}}}, set, get)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"one=true,width=4", "one=true,width=5", "one=false,width=4", "one=false,width=5"}
+	if len(points) != len(want) {
+		t.Fatalf("want %v points, but returned %v", len(want), len(points))
+	}
+	for i := range want {
+		p := points[i]
+		if p.Label != want[i] || p.Options.Width != 4+i%2 || p.Options.One != (i < 2) {
+			t.Errorf("want %v, but returned %v (width %v, one %v)", want[i], p.Label, p.Options.Width, p.Options.One)
+		}
+	}
+	if o.Width != 3 || o.One == false {
+		t.Errorf("want options restored, but returned width %v, one %v", o.Width, o.One)
+	}
+	if _, err = EvalPoints(o, []EvalAxis{{"height", []string{"1"}}}, set, get); err == nil {
+		t.Error("want error for unknown option")
+	}
+
+	// 軸がなければ元のオプションだけ
+	points, err = EvalPoints(o, nil, set, get)
+	if err != nil || len(points) != 1 || points[0].Label != "" || points[0].Options == o {
+		t.Errorf("want one copied point, but returned %+v, %v", points, err)
+	}
+}
+
+func TestRunEval(t *testing.T) {
+	o := tAnalyzerOptions()
+	o.Height = -1
+	o.Width = 4
+	o.MaxWidth = 4
+	o.One = true
+	i := &Instance{
+		Analyzer: &tAnalyzer{Output: map[string]string{"みかんはあまい。": tKnpMikan}},
+	}
+	docs := []BatchDocument{
+		{ID: "mikan", Text: "みかんはあまい。\n"},
+		{ID: "ringo", Text: "りんご\n"},
+	}
+	var b bytes.Buffer
+	cases, err := RunEval(context.Background(), i, docs, [][]rune{[]rune("みあ")},
+		[]EvalPoint{{Label: "width=4", Options: o}}, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) != 2 {
+		t.Fatalf("want 2 cases, but returned %+v", cases)
+	}
+	if c := cases[0]; c.Status != "ok" || c.FirstWidth != 4 || c.Nodes == 0 || c.Options != "width=4" {
+		t.Errorf("unexpected case: %+v", c)
+	}
+	if c := cases[1]; c.Status != "error" || c.Results != 0 || c.FirstWidth != 0 {
+		t.Errorf("want error for unknown text, but returned %+v", c)
+	}
+	records, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[0][0] != "sentence" || records[1][4] != "true" || records[2][5] != "" {
+		t.Errorf("unexpected CSV: %q", records)
+	}
+
+	summary := SummarizeEval(cases)
+	if len(summary) != 1 {
+		t.Fatalf("want 1 summary, but returned %+v", summary)
+	}
+	if s := summary[0]; s.Cases != 2 || s.Successes != 1 || s.Errors != 1 || s.Rate() != 50 || s.FirstWidth != 4 {
+		t.Errorf("unexpected summary: %+v", s)
+	}
+}
@@ -3,22 +3,26 @@ package acrostic
 import (
 	"bufio"
 	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 
-	"github.com/kr/pty"
 	"github.com/noyuno/lgo/runes"
 	log "github.com/sirupsen/logrus"
 )
 
 type JumanKnp struct {
-	JumanPipe *os.File
-	JKPipe    *os.File
+	// JumanPool : jumanのワーカー(起動していなければnil)
+	JumanPool *ToolPool
+	// KnpPool : juman|knpのワーカー(起動していなければnil)
+	KnpPool *ToolPool
 	//Imis      *os.File
 
 	InflectionDB        map[string]map[string]string
 	inflectionTypeCache map[string][]rune
+	inflectionMutex     sync.Mutex
 	Options             *Options
 	Instance            *Instance
 }
@@ -28,26 +32,28 @@ func NewJumanKnp(o *Options, i *Instance) (*JumanKnp, error) {
 	ret := new(JumanKnp)
 	ret.Options = o
 	ret.Instance = i
-	c := strings.Split(ret.Options.JumanCommand, " ")[0]
-	err = exec.Command("which", c).Run()
+	// 記録しておいたKNPの出力を使うときは，KNPを起動しない．
+	// JUMANがなくても，活用形を使わずに続行する．
+	replay := ret.Options.Mode == "knp-input"
+	ret.JumanPool, err = NewToolPool("juman", ret.Options.JumanCommand,
+		ret.Options.AnalyzerWorkers, ret.Options.AnalyzerTimeout)
 	if err != nil {
-		return nil, errors.New("command not found: " + c)
-	}
-	err = exec.Command("which", strings.Split(ret.Options.KnpCommand, " ")[0]).Run()
-	if err != nil {
-		return nil, errors.New("JumanKnp.KnpCommand not found: " + ret.Options.KnpCommand)
-	}
-
-	j := exec.Command("sh", "-c", ret.Options.JumanCommand)
-	ret.JumanPipe, err = pty.Start(j)
-	if err != nil {
-		return nil, err
+		if replay == false {
+			return nil, err
+		}
+		log.Warnf("%v, inflection is disabled", err.Error())
 	}
-	//log.Debug("sh -c " + ret.Options.JumanCommand + "|" + ret.Options.KnpCommand)
-	jk := exec.Command("sh", "-c", ret.Options.JumanCommand+"|"+ret.Options.KnpCommand)
-	ret.JKPipe, err = pty.Start(jk)
-	if err != nil {
-		return nil, err
+	if replay == false {
+		err = exec.Command("which", strings.Split(ret.Options.KnpCommand, " ")[0]).Run()
+		if err != nil {
+			return nil, errors.New("JumanKnp.KnpCommand not found: " + ret.Options.KnpCommand)
+		}
+		//log.Debug("sh -c " + ret.Options.JumanCommand + "|" + ret.Options.KnpCommand)
+		ret.KnpPool, err = NewToolPool("knp", ret.Options.JumanCommand+"|"+ret.Options.KnpCommand,
+			ret.Options.AnalyzerWorkers, ret.Options.AnalyzerTimeout)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	//ret.Imis, err = os.Open(ret.Options.JumanPPDirectory + "/dic.imis")
@@ -55,52 +61,79 @@ func NewJumanKnp(o *Options, i *Instance) (*JumanKnp, error) {
 	//	return nil, err
 	//}
 
+	ret.InflectionDB = map[string]map[string]string{}
 	err = ret.ReadInflection()
 	if err != nil {
-		return nil, err
+		if replay == false {
+			return nil, err
+		}
+		log.Warnf("unable to read inflection database: %v", err.Error())
 	}
 
 	ret.inflectionTypeCache = map[string][]rune{}
 	return ret, nil
 }
 
-func (jk *JumanKnp) Execute(text []rune, knp bool) []rune {
-	var f *os.File
+// Close : jumanとknpのワーカーを終了する
+func (jk *JumanKnp) Close() {
+	if jk.JumanPool != nil {
+		jk.JumanPool.Close()
+	}
+	if jk.KnpPool != nil {
+		jk.KnpPool.Close()
+	}
+}
+
+// Execute : jumanまたはknpを実行する
+// 空の文を渡したときはErrEmptyInputを返す
+// 複数のgoroutineから呼んでもよい（Options.AnalyzerWorkersの数まで同時に実行する）
+func (jk *JumanKnp) Execute(text []rune, knp bool) ([]rune, error) {
+	kind := CacheJuman
 	if knp {
-		f = jk.JKPipe
-	} else {
-		f = jk.JumanPipe
+		kind = CacheKnp
 	}
 	//log.Debugf("JumanKnp.Execute: %v", string(text))
 	// 入力チェック
 	if strings.TrimSpace(string(text)) == "" {
-		log.Fatalf("JumanKnp.Execute: empty input")
-		return nil
+		return nil, fmt.Errorf("JumanKnp.Execute: %w", ErrEmptyInput)
+	}
+	// キャッシュにあれば，コマンドが動いていなくても使う
+	if v, ok := jk.Instance.Cache.Get(kind, string(text)); ok {
+		return []rune(v), nil
+	}
+	var p *ToolPool
+	if knp {
+		p = jk.KnpPool
+	} else {
+		p = jk.JumanPool
+	}
+	if p == nil {
+		log.Warnf("JumanKnp.Execute: command is not running (knp=%v)", knp)
+		return nil, nil
 	}
 
-	f.Write([]byte(string(text) + "\n"))
-	s := bufio.NewScanner(f)
-	ret := make([]rune, 0, 100)
-	i := 0
-	for s.Scan() {
-		t := s.Text()
-		if i == 0 {
-			i++
-			continue
-		}
-		ret = append(ret, []rune(t)...)
-		ret = append(ret, []rune("\n")...)
-		if t == "EOS" {
-			break
-		}
-		i++
+	lines, err := p.Execute(string(text), func(line string) bool {
+		return line == "EOS"
+	})
+	if err != nil {
+		return nil, fmt.Errorf("JumanKnp.Execute: %w", err)
 	}
 	out := ""
-	for i := range ret {
-		out += string(ret[i])
+	for i := range lines {
+		out += lines[i] + "\n"
 	}
 	//log.Debugf("JumanKnp.Execute: %v;", out)
-	return ret
+	jk.Instance.Cache.Set(kind, string(text), out)
+	return []rune(out), nil
+}
+
+// Analyze : Analyzerの実装．jumanpp | knpで解析する
+func (jk *JumanKnp) Analyze(text []rune) ([][][]rune, error) {
+	out, err := jk.Execute(text, true)
+	if err != nil {
+		return nil, err
+	}
+	return SplitKnp(out), nil
 }
 
 type JumanKnpVerb struct {
@@ -162,21 +195,36 @@ func (jk *JumanKnp) Inflection(text []rune, form []rune) ([]rune, []rune, bool)
 	eost := []rune("EOS")
 	lft := []rune("\n")
 	itype := []rune("")
-	if itype, o = jk.inflectionTypeCache[string(text)]; !o {
-		j := jk.Execute(text, false)
-		for _, line := range runes.Split(j, lft) {
-			if runes.Compare(line[0:1], att) || runes.Compare(line[0:3], eost) {
-				continue
+	jk.inflectionMutex.Lock()
+	itype, o = jk.inflectionTypeCache[string(text)]
+	jk.inflectionMutex.Unlock()
+	if !o {
+		if v, ok := jk.Instance.Cache.Get(CacheInflection, string(text)); ok {
+			itype = []rune(v)
+		} else {
+			j, err := jk.Execute(text, false)
+			if err != nil {
+				log.Warnf("JumanKnp.Inflection: %v", err.Error())
+			}
+			if len(j) == 0 {
+				jk.setInflectionType(text, itype)
+				return nil, nil, false
 			}
+			for _, line := range runes.Split(j, lft) {
+				if runes.Compare(line[0:1], att) || runes.Compare(line[0:3], eost) {
+					continue
+				}
 
-			// 「押さえ込む」 = 「押さえ」「込む」で、活用は「込む」なので上書き
-			out := runes.Split(line, spacet)
-			if NewPart(out[3]).IsFlection() {
+				// 「押さえ込む」 = 「押さえ」「込む」で、活用は「込む」なので上書き
+				out := runes.Split(line, spacet)
+				if NewPart(out[3]).IsFlection() {
 
+				}
+				itype = out[7]
 			}
-			itype = out[7]
+			jk.Instance.Cache.Set(CacheInflection, string(text), string(itype))
 		}
-		jk.inflectionTypeCache[string(text)] = itype
+		jk.setInflectionType(text, itype)
 		if runes.Compare(itype, []rune("")) {
 			return nil, nil, false
 		}
@@ -203,6 +251,12 @@ func (jk *JumanKnp) Inflection(text []rune, form []rune) ([]rune, []rune, bool)
 	return itype, nil, false
 }
 
+func (jk *JumanKnp) setInflectionType(text []rune, itype []rune) {
+	jk.inflectionMutex.Lock()
+	jk.inflectionTypeCache[string(text)] = itype
+	jk.inflectionMutex.Unlock()
+}
+
 // InflectionPolite : 活用する語(動詞，形容詞，形容動詞)を丁寧にした語を取得する
 // text : 活用する語の表層の原形
 // past: true: 過去, false: 現在
@@ -253,7 +307,13 @@ func (jk *JumanKnp) GetKana(text []rune) []rune {
 	spacet := []rune(" ")
 	att := []rune("@")
 	lft := []rune("\n")
-	out := jk.Execute(text, false)
+	out, err := jk.Execute(text, false)
+	if err != nil {
+		log.Warnf("JumanKnp.GetKana: %v", err.Error())
+	}
+	if len(out) == 0 {
+		return nil
+	}
 	t := runes.Split(out, lft)
 	var ret []rune
 	for i := range t {
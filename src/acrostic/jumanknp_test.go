@@ -23,9 +23,15 @@ func TestJumanKnpExecute(t *testing.T) {
 		t.FailNow()
 	}
 	t.Logf("execute")
-	jk.Execute([]rune("2丁目の花子さんは日曜日に一郎さんとピクニックに行った．"), true)
+	_, err = jk.Execute([]rune("2丁目の花子さんは日曜日に一郎さんとピクニックに行った．"), true)
+	if err != nil {
+		t.Error(err)
+	}
 	//t.Logf("%v\n", string(r))
-	jk.Execute([]rune("帽子を被った田中さんと横山さんはゲームセンターに行くようだ．"), false)
+	_, err = jk.Execute([]rune("帽子を被った田中さんと横山さんはゲームセンターに行くようだ．"), false)
+	if err != nil {
+		t.Error(err)
+	}
 	//t.Logf("%v\n", string(r))
 }
 
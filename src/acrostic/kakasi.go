@@ -1,18 +1,12 @@
 package acrostic
 
 import (
-	"bufio"
-	"errors"
-	"os"
-	"os/exec"
-	"strings"
-
-	"github.com/kr/pty"
 	log "github.com/sirupsen/logrus"
 )
 
 type Kakasi struct {
-	KakasiPipe *os.File
+	// Pool : kakasiのワーカー
+	Pool *ToolPool
 
 	Options *Options
 }
@@ -21,33 +15,25 @@ func NewKakasi(o *Options) (*Kakasi, error) {
 	var err error
 	ret := new(Kakasi)
 	ret.Options = o
-	c := strings.Split(ret.Options.KakasiCommand, " ")[0]
-	err = exec.Command("which", c).Run()
-	if err != nil {
-		return nil, errors.New("command not found: " + c)
-	}
-
-	j := exec.Command("sh", "-c", ret.Options.KakasiCommand)
-	ret.KakasiPipe, err = pty.Start(j)
+	ret.Pool, err = NewToolPool("kakasi", ret.Options.KakasiCommand,
+		ret.Options.AnalyzerWorkers, ret.Options.AnalyzerTimeout)
 	if err != nil {
 		return nil, err
 	}
 	return ret, nil
 }
 
+// Close : ワーカーを終了する
+func (k *Kakasi) Close() {
+	k.Pool.Close()
+}
+
 func (k *Kakasi) GetKana(text []rune) []rune {
-	k.KakasiPipe.Write([]byte(string(text) + "\n"))
-	s := bufio.NewScanner(k.KakasiPipe)
-	ret := make([]rune, 0, 10)
-	i := 0
-	for s.Scan() {
-		if i == 0 {
-			i++
-			continue
-		}
-		log.Debug(s.Text())
-		ret = append(ret, []rune(s.Text())...)
-		break
+	lines, err := k.Pool.Execute(string(text), func(string) bool { return true })
+	if err != nil {
+		log.Warnf("Kakasi.GetKana: %v", err.Error())
+		return nil
 	}
-	return ret
+	log.Debug(lines[0])
+	return []rune(lines[0])
 }
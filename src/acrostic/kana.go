@@ -2,7 +2,9 @@ package acrostic
 
 import (
 	"strings"
+	"sync"
 
+	"github.com/mattn/go-runewidth"
 	"github.com/noyuno/lgo/runes"
 	log "github.com/sirupsen/logrus"
 )
@@ -65,6 +67,7 @@ type Kana struct {
 	Options   *Options
 	Instance  *Instance
 	kanaCache map[string][]rune
+	mutex     sync.Mutex
 }
 
 func NewKana(o *Options, i *Instance) *Kana {
@@ -76,9 +79,16 @@ func NewKana(o *Options, i *Instance) *Kana {
 }
 
 func (k *Kana) Get(text []rune) ([]rune, bool) {
-	if v, o := k.kanaCache[string(text)]; o {
+	k.mutex.Lock()
+	v, o := k.kanaCache[string(text)]
+	k.mutex.Unlock()
+	if o {
 		return v, true
 	}
+	if v, o := k.Instance.Cache.Get(CacheKana, string(text)); o {
+		k.set(text, []rune(v))
+		return []rune(v), true
+	}
 	ret := []rune("")
 	for _, mode := range k.Options.KanaModeOrder {
 		if mode == "juman" {
@@ -92,7 +102,8 @@ func (k *Kana) Get(text []rune) ([]rune, bool) {
 			ret = k.Instance.Kakasi.GetKana(text)
 		}
 		if HasOnlyKana(ret) {
-			k.kanaCache[string(text)] = ret
+			k.set(text, ret)
+			k.Instance.Cache.Set(CacheKana, string(text), string(ret))
 			return ret, true
 		}
 		log.WithFields(log.Fields{
@@ -107,10 +118,76 @@ func (k *Kana) Get(text []rune) ([]rune, bool) {
 	return nil, false
 }
 
+func (k *Kana) set(text []rune, kana []rune) {
+	k.mutex.Lock()
+	k.kanaCache[string(text)] = kana
+	k.mutex.Unlock()
+}
+
+// Wide : 1文字を全角2桁の幅で表示する文字列．半角の文字は空白で埋める
+// 行列の1マスを1文字として探索するので，表示もマスの幅をそろえる
 func Wide(c rune) string {
 	cc := string(c)
-	if strings.Contains(NumberList, cc) || strings.Contains(AlphabetList, cc) {
-		return cc + " "
+	if w := runewidth.RuneWidth(c); w < 2 {
+		return cc + strings.Repeat(" ", 2-w)
 	}
 	return cc
 }
+
+// HankakuKatakanaList : 半角カタカナ(濁点と半濁点を除く)
+const HankakuKatakanaList = `｡｢｣､･ｦｧｨｩｪｫｬｭｮｯｰｱｲｳｴｵｶｷｸｹｺｻｼｽｾｿﾀﾁﾂﾃﾄﾅﾆﾇﾈﾉﾊﾋﾌﾍﾎﾏﾐﾑﾒﾓﾔﾕﾖﾗﾘﾙﾚﾛﾜﾝ`
+
+// ZenkakuKatakanaList : HankakuKatakanaListに対応する全角の文字
+const ZenkakuKatakanaList = `。「」、・ヲァィゥェォャュョッーアイウエオカキクケコサシスセソタチツテトナニヌネノハヒフヘホマミムメモヤユヨラリルレロワン`
+
+// ToFullWidth : 半角の英数字，記号，空白，カタカナを全角にする(--full-width)
+// 半角カタカナの濁点と半濁点は前の文字と合わせる．改行などの制御文字はそのまま
+func ToFullWidth(text []rune) []rune {
+	hankaku := []rune(HankakuKatakanaList)
+	zenkaku := []rune(ZenkakuKatakanaList)
+	ret := make([]rune, 0, len(text))
+	for _, c := range text {
+		switch {
+		case c == ' ':
+			ret = append(ret, '　')
+		case '!' <= c && c <= '~':
+			ret = append(ret, c+0xfee0)
+		case c == 'ﾞ' || c == 'ﾟ':
+			if n := len(ret); n > 0 {
+				if v, ok := voiced(ret[n-1], c == 'ﾟ'); ok {
+					ret[n-1] = v
+					continue
+				}
+			}
+			if c == 'ﾞ' {
+				ret = append(ret, '゛')
+			} else {
+				ret = append(ret, '゜')
+			}
+		default:
+			if i := runes.Index(hankaku, []rune{c}, 0); i >= 0 {
+				ret = append(ret, zenkaku[i])
+			} else {
+				ret = append(ret, c)
+			}
+		}
+	}
+	return ret
+}
+
+// voiced : 全角カタカナに濁点(semiがtrueならば半濁点)を付けた文字
+func voiced(c rune, semi bool) (rune, bool) {
+	if semi {
+		if strings.ContainsRune("ハヒフヘホ", c) {
+			return c + 2, true
+		}
+		return c, false
+	}
+	if c == 'ウ' {
+		return 'ヴ', true
+	}
+	if strings.ContainsRune("カキクケコサシスセソタチツテトハヒフヘホ", c) {
+		return c + 1, true
+	}
+	return c, false
+}
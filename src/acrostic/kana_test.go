@@ -0,0 +1,31 @@
+package acrostic
+
+import "testing"
+
+func TestToFullWidth(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want string
+	}{
+		{"2018年", "２０１８年"},
+		{"AI, OK!", "ＡＩ，　ＯＫ！"},
+		{"ｶﾞｯｺｳﾆ ﾊﾟﾝ", "ガッコウニ　パン"},
+		{"ｱﾞ", "ア゛"},
+		{"みかん\n", "みかん\n"},
+	} {
+		if got := string(ToFullWidth([]rune(c.in))); got != c.want {
+			t.Errorf("ToFullWidth(%q): want %q, but returned %q", c.in, c.want, got)
+		}
+	}
+}
+
+func TestWide(t *testing.T) {
+	for _, c := range []rune("2Aｱ(") {
+		if got := Wide(c); got != string(c)+" " {
+			t.Errorf("Wide(%q): want padded, but returned %q", c, got)
+		}
+	}
+	if got := Wide('年'); got != "年" {
+		t.Errorf("Wide('年'): want 年, but returned %q", got)
+	}
+}
@@ -0,0 +1,67 @@
+package acrostic
+
+import "strings"
+
+// DefaultKinsokuStart : 行頭に置かない文字(--kinsoku-start)
+const DefaultKinsokuStart = "、。，．,.・：；:;？！?!ー－～…‥」』）］｝〕〉》】ゝゞヽヾ々" +
+	"ぁぃぅぇぉっゃゅょゎゕゖァィゥェォッャュョヮヵヶ"
+
+// DefaultKinsokuEnd : 行末に置かない文字(--kinsoku-end)
+const DefaultKinsokuEnd = "「『（［｛〔〈《【"
+
+// newRow : 行列の1行．ぶら下げるときは，行末の外に1文字分のマスを足す
+func (m *ArrangeMatrix) newRow() []rune {
+	if m.Options.Kinsoku && m.Options.KinsokuHanging != "" {
+		return make([]rune, m.Width+1)
+	}
+	return make([]rune, m.Width)
+}
+
+// placeKinsoku : 基本句のパターンpのi番目の文字をmatposに置くときの禁則処理(--kinsoku)
+// 折り返した行の先頭に行頭禁則の文字が来るか，折り返す行の末尾に行末禁則の文字が来るならば失敗する．
+// ただし，ぶら下げられる文字は，前の行の行末の外(列Width)に置き，hungを返す．
+// 改行した行の先頭は元の文のとおりなので確かめない．
+// mat, matline: この基本句の行列と，その先頭の行の位置
+// newline: この基本句の前で改行したかどうか
+func (m *ArrangeMatrix) placeKinsoku(
+	mat [][]rune, matline int, p []rune, i int, matpos []int, newline bool) (hung bool, ok bool) {
+	o := m.Options
+	c := p[i]
+	if matpos[1] == 0 && matpos[0] > 0 && (i > 0 || newline == false) {
+		// 行頭
+		if i > 0 && strings.ContainsRune(o.KinsokuHanging, c) {
+			// 前の文字はこの基本句の前の行の行末にあるので，その外にぶら下げる
+			row := mat[matpos[0]-matline-1]
+			if len(row) <= m.Width || row[m.Width] != 0 {
+				return false, false
+			}
+			row[m.Width] = c
+			return true, true
+		}
+		if strings.ContainsRune(o.KinsokuStart, c) {
+			return false, false
+		}
+	}
+	if matpos[1] == m.Width-1 && strings.ContainsRune(o.KinsokuEnd, c) && m.lineContinues(p, i) {
+		// 行末
+		return false, false
+	}
+	return false, true
+}
+
+// lineContinues : p[i]の次に，改行せずに続く文字があるかどうか
+func (m *ArrangeMatrix) lineContinues(p []rune, i int) bool {
+	if i+1 < len(p) {
+		return true
+	}
+	next := m.BasicPhraseIndex + 1
+	return next < len(m.BasicPhrases) && m.BasicPhrases[next].NewLine == false
+}
+
+// trimHanging : 最後の文字をぶら下げたときに，書かなかった末尾の空の行を除く
+func trimHanging(mat [][]rune, matpos []int, matline int) [][]rune {
+	if matpos[1] == 0 && len(mat) > matpos[0]-matline {
+		return mat[:matpos[0]-matline]
+	}
+	return mat
+}
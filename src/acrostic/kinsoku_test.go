@@ -0,0 +1,57 @@
+package acrostic
+
+import (
+	"context"
+	"testing"
+)
+
+func TestArrangeMatrixSearchKinsoku(t *testing.T) {
+	for _, c := range []struct {
+		surfaces []string
+		hanging  string
+		want     int
+	}{
+		// 禁則処理をしなければ見つかる
+		{[]string{"あみい", "うかえ", "おんか"}, "", 1},
+		// 折り返した行頭に「、」
+		{[]string{"あみい", "、かえ", "おんか"}, "", 0},
+		// 折り返す行末に「「」
+		{[]string{"あみ「", "うかえ", "おんか"}, "", 0},
+		// 行頭に来る「、」をぶら下げる
+		{[]string{"あみい、", "うかえ", "おんか"}, "、", 1},
+		// ぶら下げなければ行頭禁則になる
+		{[]string{"あみい、", "うかえ", "おんか"}, "", 0},
+	} {
+		o := &Options{Height: 6, MatchLength: true, Silent: true,
+			Kinsoku: true, KinsokuStart: DefaultKinsokuStart, KinsokuEnd: DefaultKinsokuEnd,
+			KinsokuHanging: c.hanging}
+		am := tSearchMatrixWith(t, context.Background(), o, 3, "みかん", c.surfaces...)
+		if len(am.MatrixResult) != c.want {
+			t.Errorf("%v hanging %q: want %v results, but returned %v",
+				c.surfaces, c.hanging, c.want, len(am.MatrixResult))
+		}
+	}
+
+	// ぶら下げた文字は行末の外に出る
+	o := &Options{Height: 6, MatchLength: true, Silent: true,
+		Kinsoku: true, KinsokuStart: DefaultKinsokuStart, KinsokuHanging: "、"}
+	am := tSearchMatrixWith(t, context.Background(), o, 3, "みかん", "あみい、", "うかえ", "おんか")
+	if len(am.MatrixResult) != 1 {
+		t.Fatalf("want 1 result, but returned %v", len(am.MatrixResult))
+	}
+	rows := matrixRows(am.MatrixResult[0].Matrix)
+	if len(rows) != 3 || string(rows[0]) != "あみい、" || string(rows[1]) != "うかえ" {
+		t.Errorf("want 、 hung after あみい, but returned %q", rows)
+	}
+}
+
+func TestRenderColumns(t *testing.T) {
+	r := tRenderResult()
+	if n := renderColumns(r); n != r.Width {
+		t.Errorf("want %v columns, but returned %v", r.Width, n)
+	}
+	r.Matrix[0] = append(r.Matrix[0][:r.Width:r.Width], '、')
+	if n := renderColumns(r); n != r.Width+1 {
+		t.Errorf("want %v columns with a hung character, but returned %v", r.Width+1, n)
+	}
+}
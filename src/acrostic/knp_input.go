@@ -0,0 +1,129 @@
+package acrostic
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// KnpInput : 記録しておいたknp -tabの出力を読み込むAnalyzer
+// --knp-onlyで出力したファイルをそのまま使うことができる．
+// 文は形態素の表層をつなげた文字列で引く．
+type KnpInput struct {
+	Options *Options
+	// Sentences : 表層 -> 文節ごとに分割された行
+	Sentences map[string][][][]rune
+}
+
+// NewKnpInput : constructor
+// Options.KnpInputがディレクトリであれば，その中のファイルをすべて読み込む
+func NewKnpInput(o *Options) (*KnpInput, error) {
+	ret := new(KnpInput)
+	ret.Options = o
+	ret.Sentences = map[string][][][]rune{}
+	if o.KnpInput == "" {
+		return nil, errors.New("require knp input filename (--knp-input)")
+	}
+	st, err := os.Stat(o.KnpInput)
+	if err != nil {
+		return nil, err
+	}
+	if st.IsDir() {
+		files, err := ioutil.ReadDir(o.KnpInput)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0)
+		for _, f := range files {
+			if f.IsDir() == false {
+				names = append(names, f.Name())
+			}
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			err = ret.Read(filepath.Join(o.KnpInput, n))
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		err = ret.Read(o.KnpInput)
+		if err != nil {
+			return nil, err
+		}
+	}
+	log.Debugf("KnpInput: %v sentences loaded from %v", len(ret.Sentences), o.KnpInput)
+	return ret, nil
+}
+
+// Read : EOSで区切られたknp -tabの出力を読み込む
+func (k *KnpInput) Read(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	block := make([]rune, 0, 1000)
+	for scanner.Scan() {
+		t := scanner.Text()
+		block = append(block, []rune(t+"\n")...)
+		if t == "EOS" {
+			k.Add(SplitKnp(block))
+			block = block[:0]
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(block)) != "" {
+		return fmt.Errorf("KnpInput: %v: missing EOS at the end of file", filename)
+	}
+	return nil
+}
+
+// Add : 文節ごとに分割された行を登録する
+func (k *KnpInput) Add(phrases [][][]rune) {
+	if len(phrases) == 0 {
+		return
+	}
+	k.Sentences[knpInputKey(KnpSurface(phrases))] = phrases
+}
+
+// Analyze : Analyzerの実装
+func (k *KnpInput) Analyze(text []rune) ([][][]rune, error) {
+	if v, ok := k.Sentences[knpInputKey(string(text))]; ok {
+		return v, nil
+	}
+	return nil, fmt.Errorf("KnpInput: sentence not found in %v: %v", k.Options.KnpInput, string(text))
+}
+
+// KnpSurface : 文節ごとに分割された行から，形態素の表層をつなげた文字列を返す
+func KnpSurface(phrases [][][]rune) string {
+	ret := ""
+	for _, p := range phrases {
+		for _, line := range p {
+			if len(line) == 0 {
+				continue
+			}
+			switch string(line[0]) {
+			case "*", "+", "#", "@":
+				continue
+			}
+			ret += strings.SplitN(string(line), " ", 2)[0]
+		}
+	}
+	return ret
+}
+
+func knpInputKey(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
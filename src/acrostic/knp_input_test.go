@@ -0,0 +1,62 @@
+package acrostic
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const tKnpKaki = `# S-ID:2 KNP:4.18
+* -1D <体言>
++ -1D <体言>
+かき かき かき 名詞 6 普通名詞 1 * 0 * 0 "代表表記:柿/かき" <自立>
+を を を 助詞 9 格助詞 1 * 0 * 0 NIL <付属>
+EOS
+`
+
+func TestKnpInput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acrostic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// --knp-onlyの出力と同じく，EOSのあとに空行があってもよい
+	err = ioutil.WriteFile(filepath.Join(dir, "0.knp"), []byte(tKnpMikan+"\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "1.knp"), []byte(tKnpKaki), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := tAnalyzerOptions()
+	o.Mode = "knp-input"
+	o.KnpInput = dir
+	a, err := NewAnalyzer(o, &Instance{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := a.Analyze([]rune("みかんはあまい。"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p) != 2 {
+		t.Errorf("want 2 phrases, but returned %v", len(p))
+	}
+	if _, err = a.Analyze([]rune("かきを")); err != nil {
+		t.Error(err)
+	}
+	if _, err = a.Analyze([]rune("りんご")); err == nil {
+		t.Errorf("want error for unknown sentence")
+	}
+
+	s := NewSentence(o, &Instance{Analyzer: a}, []rune("みかんはあまい。"), false, [][]rune{[]rune("みか")})
+	if _, err = s.Analyze(0); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.BasicPhrases) != 2 {
+		t.Errorf("want 2 basic phrases, but returned %v", len(s.BasicPhrases))
+	}
+}
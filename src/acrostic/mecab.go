@@ -1,17 +1,12 @@
 package acrostic
 
 import (
-	"bufio"
-	"errors"
-	"os"
-	"os/exec"
-	"strings"
-
-	"github.com/kr/pty"
+	log "github.com/sirupsen/logrus"
 )
 
 type MeCab struct {
-	MeCabPipe *os.File
+	// Pool : mecabのワーカー
+	Pool *ToolPool
 
 	Options *Options
 }
@@ -20,32 +15,24 @@ func NewMeCab(o *Options) (*MeCab, error) {
 	var err error
 	ret := new(MeCab)
 	ret.Options = o
-	c := strings.Split(ret.Options.MeCabCommand, " ")[0]
-	err = exec.Command("which", c).Run()
-	if err != nil {
-		return nil, errors.New("command not found: " + c)
-	}
-
-	j := exec.Command("sh", "-c", ret.Options.MeCabCommand)
-	ret.MeCabPipe, err = pty.Start(j)
+	ret.Pool, err = NewToolPool("mecab", ret.Options.MeCabCommand,
+		ret.Options.AnalyzerWorkers, ret.Options.AnalyzerTimeout)
 	if err != nil {
 		return nil, err
 	}
 	return ret, nil
 }
 
+// Close : ワーカーを終了する
+func (m *MeCab) Close() {
+	m.Pool.Close()
+}
+
 func (m *MeCab) GetKana(text []rune) []rune {
-	m.MeCabPipe.Write([]byte(string(text) + "\n"))
-	s := bufio.NewScanner(m.MeCabPipe)
-	o := ""
-	i := 0
-	for s.Scan() {
-		if i == 0 {
-			i++
-			continue
-		}
-		o += s.Text()
-		break
+	lines, err := m.Pool.Execute(string(text), func(string) bool { return true })
+	if err != nil {
+		log.Warnf("MeCab.GetKana: %v", err.Error())
+		return nil
 	}
-	return []rune(o)
+	return []rune(lines[0])
 }
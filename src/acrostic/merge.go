@@ -0,0 +1,151 @@
+package acrostic
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sort"
+	"unicode"
+)
+
+// ReadResultRecords : --format json(配列)またはjsonl(1行に1件)で書き出した結果を読み取る
+func ReadResultRecords(r io.Reader) ([]ResultRecord, error) {
+	br := bufio.NewReader(r)
+	ret := make([]ResultRecord, 0)
+	// 先頭の空白を読み飛ばし，配列かどうかを見る
+	array := false
+	for {
+		c, _, err := br.ReadRune()
+		if err == io.EOF {
+			return ret, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if unicode.IsSpace(c) {
+			continue
+		}
+		array = c == '['
+		err = br.UnreadRune()
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+	d := json.NewDecoder(br)
+	if array {
+		err := d.Decode(&ret)
+		if err != nil {
+			return nil, err
+		}
+		return ret, nil
+	}
+	for {
+		var rec ResultRecord
+		err := d.Decode(&rec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, rec)
+	}
+	return ret, nil
+}
+
+// resultRecordKey : 重複を見分けるためのキー(キーワード，幅，行列とキーワードの位置)
+func resultRecordKey(r ResultRecord) (string, error) {
+	b, err := json.Marshal(struct {
+		Keyword        string
+		Width          int
+		Rows           []string
+		KeywordColumn  ResultKeywordColumn
+		HiddenKeywords []ResultKeywordColumn
+	}{r.Keyword, r.Width, r.Rows, r.KeywordColumn, r.HiddenKeywords})
+	return string(b), err
+}
+
+// MergeResultRecords : --shardで分けて探索した結果をまとめる
+// 重複を除き，キーワード，幅，文パターン，パターンのスタックの順に並べる
+func MergeResultRecords(lists ...[]ResultRecord) ([]ResultRecord, error) {
+	ret := make([]ResultRecord, 0)
+	seen := map[string]bool{}
+	for _, list := range lists {
+		for _, r := range list {
+			key, err := resultRecordKey(r)
+			if err != nil {
+				return nil, err
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			ret = append(ret, r)
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		a, b := ret[i], ret[j]
+		if a.KeywordIndex != b.KeywordIndex {
+			return a.KeywordIndex < b.KeywordIndex
+		}
+		if a.Width != b.Width {
+			return a.Width < b.Width
+		}
+		if a.PatternIndex != b.PatternIndex {
+			return a.PatternIndex < b.PatternIndex
+		}
+		if c := compareStack(a.PatternStack, b.PatternStack); c != 0 {
+			return c < 0
+		}
+		return compareStack(a.BranchStack, b.BranchStack) < 0
+	})
+	return ret, nil
+}
+
+// compareStack : スタックを辞書順に比べる(a < bならば負，a == bならば0，a > bならば正)
+func compareStack(a []int, b []int) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] - b[i]
+		}
+	}
+	return len(a) - len(b)
+}
+
+// NewResultFromRecord : ResultRecordからResultを作り直す(NewResultRecordの逆)
+// 行列の埋まっていない部分は0にする
+func NewResultFromRecord(r ResultRecord) Result {
+	ret := Result{
+		Keyword:       []rune(r.Keyword),
+		KeywordNumber: r.KeywordIndex,
+		Width:         r.Width,
+		PatternNumber: r.PatternIndex,
+		Surface:       []rune(r.Surface),
+		Matrix:        make([][]rune, len(r.Rows)),
+		KeywordEnd:    []int{r.KeywordColumn.EndRow, r.KeywordColumn.Column},
+		PatternStack:  r.PatternStack,
+		BranchStack:   r.BranchStack,
+		Score:         r.Score,
+		Step:          r.KeywordColumn.Step,
+	}
+	for i, row := range r.Rows {
+		line := []rune(row)
+		width := r.Width
+		if width < len(line) {
+			width = len(line)
+		}
+		ret.Matrix[i] = make([]rune, width)
+		copy(ret.Matrix[i], line)
+	}
+	for _, h := range r.HiddenKeywords {
+		ret.Hidden = append(ret.Hidden, HiddenKeyword{
+			Keyword:    []rune(h.Keyword),
+			KeywordEnd: []int{h.EndRow, h.Column},
+		})
+	}
+	for _, r := range r.Ruby {
+		ret.Ruby = append(ret.Ruby, Ruby{Start: r.Start, Length: r.Length, Text: []rune(r.Text)})
+	}
+	return ret
+}
@@ -0,0 +1,68 @@
+package acrostic
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReadResultRecords(t *testing.T) {
+	array := `[
+  {"keyword": "みかん", "width": 3, "rows": ["あみい"], "pattern_stack": [0]},
+  {"keyword": "みかん", "width": 3, "rows": ["うかえ"], "pattern_stack": [1]}
+]`
+	lines := `{"keyword": "みかん", "width": 3, "rows": ["あみい"], "pattern_stack": [0]}
+{"keyword": "みかん", "width": 3, "rows": ["うかえ"], "pattern_stack": [1]}
+`
+	for _, in := range []string{array, lines, "  \n" + lines} {
+		r, err := ReadResultRecords(strings.NewReader(in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(r) != 2 || r[1].Rows[0] != "うかえ" {
+			t.Errorf("unexpected records: %v", r)
+		}
+	}
+	r, err := ReadResultRecords(strings.NewReader(""))
+	if err != nil || len(r) != 0 {
+		t.Errorf("want no records, but returned %v, %v", r, err)
+	}
+}
+
+func TestMergeResultRecords(t *testing.T) {
+	a := NewResultRecord(tRenderResult())
+	a.PatternStack = []int{1, 0}
+	b := a
+	b.Rows = []string{"あみい", "うかえ", "おん"}
+	b.PatternStack = []int{0, 2}
+	c := a
+	c.PatternStack = []int{0}
+	// cはaと同じ行列なので除く
+	r, err := MergeResultRecords([]ResultRecord{a}, []ResultRecord{b, c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 2 {
+		t.Fatalf("want 2 records, but returned %v", len(r))
+	}
+	if r[0].PatternStack[0] != 0 || r[1].PatternStack[0] != 1 {
+		t.Errorf("want sorted by pattern stack, but returned %v, %v", r[0].PatternStack, r[1].PatternStack)
+	}
+}
+
+func TestNewResultFromRecord(t *testing.T) {
+	// ResultRecordから作り直しても同じ出力になる
+	want := tRenderResult()
+	got := NewResultFromRecord(NewResultRecord(want))
+	s := NewRenderStyle(&Options{})
+	wb, gb := new(bytes.Buffer), new(bytes.Buffer)
+	if err := RenderHTML(wb, want, s); err != nil {
+		t.Fatal(err)
+	}
+	if err := RenderHTML(gb, got, s); err != nil {
+		t.Fatal(err)
+	}
+	if wb.String() != gb.String() {
+		t.Errorf("want %v, but returned %v", wb.String(), gb.String())
+	}
+}
@@ -1,6 +1,7 @@
 package acrostic
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -20,6 +21,12 @@ type Paragraph struct {
 	Instance         *Instance
 	Arrange          *Arrange
 	FoundBasicPhrase bool
+	// Handler : 結果を受け取る関数
+	Handler ResultHandler
+	// Checkpoint : --checkpoint, --resumeのチェックポイント(nilならば使わない)
+	Checkpoint *Checkpoint
+	// Nodes : 探索した状態の数(Generate, GenerateMultiを呼んだ分の合計)
+	Nodes int64
 }
 
 // NewParagraph : constructor
@@ -45,7 +52,10 @@ func (p *Paragraph) Analyze() error {
 		log.Debugf("Paragraph: %v, newline=%v", string(array[i]), newline[i])
 		sentence := NewSentence(p.Options, p.Instance, array[i], newline[i], p.Keywords)
 		if p.Options.KnpOnly {
-			s := p.Instance.JumanKnp.Execute(sentence.Text, true)
+			s, err := p.Instance.JumanKnp.Execute(sentence.Text, true)
+			if err != nil {
+				return err
+			}
 			fmt.Println(string(s))
 		} else {
 			var err error
@@ -313,16 +323,52 @@ func (p *Paragraph) CheckContainsKeyword(keyword []rune, keywordi int) bool {
 	return true
 }
 
-func (p *Paragraph) Generate(k []rune, n int, width int) (bool, error) {
+// Generate : 縦読み可能な文章を作成する．ctxが終了したら，それまでの結果を出力して戻る
+// GenerateMulti : keywordsをすべて同時にひとつの文章に隠す(--multi)
+// columns: キーワードごとの列(空ならばOptions.Column)
+func (p *Paragraph) GenerateMulti(ctx context.Context, keywords [][]rune, columns []string, width int) (bool, error) {
+	arrange, err := NewArrange(p.Options, p.Instance, p.Sentences, p.Text, 0, keywords[0], width)
+	if err != nil {
+		return false, err
+	}
+	arrange.Hidden = make([]ArrangeHidden, len(keywords))
+	for i := range keywords {
+		column := p.Options.Column
+		if i < len(columns) && columns[i] != "" {
+			column = columns[i]
+		}
+		arrange.Hidden[i] = NewArrangeHidden(keywords[i], pinnedColumn(column, width))
+	}
+	arrange.Writer.Handler = p.Handler
+	arrange.Checkpoint = p.Checkpoint
+	r, err := arrange.Arrange(ctx)
+	p.Nodes += arrange.Nodes
+	if err != nil {
+		return false, err
+	}
+	err = arrange.Output()
+	if err != nil {
+		return false, err
+	}
+	return r, nil
+}
+
+func (p *Paragraph) Generate(ctx context.Context, k []rune, n int, width int) (bool, error) {
 	r := false
 	arrange, err := NewArrange(p.Options, p.Instance, p.Sentences, p.Text, n, k, width)
 	if err != nil {
 		return false, err
 	}
-	r, err = arrange.Arrange()
+	arrange.Writer.Handler = p.Handler
+	arrange.Checkpoint = p.Checkpoint
+	r, err = arrange.Arrange(ctx)
+	p.Nodes += arrange.Nodes
+	if err != nil {
+		return false, err
+	}
+	err = arrange.Output()
 	if err != nil {
 		return false, err
 	}
-	arrange.Output()
 	return r, nil
 }
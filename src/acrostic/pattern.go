@@ -2,6 +2,7 @@ package acrostic
 
 import (
 	"errors"
+	"fmt"
 	"hash/crc32"
 	"sort"
 
@@ -180,7 +181,11 @@ func (p *Pattern) Shuffle() error {
 		}
 		row[0] = end
 		log.Debugf("row: %v", row)
-		routes = append(routes, calculateRoutes(row, 0, mat)...)
+		r, err := calculateRoutes(row, 0, mat)
+		if err != nil {
+			return err
+		}
+		routes = append(routes, r...)
 	}
 	if len(routes) == 0 {
 		return errors.New("Pattern.Routes length is 0")
@@ -278,12 +283,13 @@ func copyArray(src []int, length int) [][]int {
 // これがdepthがp.Lengthになるまで繰り返される
 // 最後に，これらの配列を集めたものが帰ってくる．
 // 当然，逆順になっているため，利用前に戻さなければならないだろう．
-func calculateRoutes(end []int, depth int, mat [][]bool) [][]int {
+// 途中の経路が得られなければErrOutOfRangeを返す
+func calculateRoutes(end []int, depth int, mat [][]bool) ([][]int, error) {
 	length := len(end)
 	var ret [][]int
 	if depth+1 >= length {
 		log.Debugf("calculateRoutes: end, %v", end)
-		return copyArray(end, length)
+		return copyArray(end, length), nil
 	}
 	e := end[depth]
 	for i := 0; i < length; i++ {
@@ -303,19 +309,21 @@ func calculateRoutes(end []int, depth int, mat [][]bool) [][]int {
 			newend := make([]int, length)
 			copy(newend, end)
 			newend[depth+1] = i
-			fret := calculateRoutes(newend, depth+1, mat)
+			fret, err := calculateRoutes(newend, depth+1, mat)
+			if err != nil {
+				return nil, err
+			}
 			if fret == nil {
-				log.Fatal("returned null")
-				return nil
+				return nil, fmt.Errorf("calculateRoutes: no route from %v: %w", newend, ErrOutOfRange)
 			}
 			ret = append(ret, fret...)
 		}
 	}
 
 	if len(ret) == 0 {
-		return copyArray(end, length)
+		return copyArray(end, length), nil
 	}
-	return ret
+	return ret, nil
 }
 
 func calculatePattern(routes [][]int) [][]int {
@@ -63,7 +63,10 @@ func TCR1(t *testing.T) {
 		[]int{3, 2, -1, -1}}
 
 	mat := IntToBoolMat(matint)
-	routes := calculateRoutes(row, 0, mat)
+	routes, err := calculateRoutes(row, 0, mat)
+	if err != nil {
+		t.Fatal(err)
+	}
 	AssertMat(t, routesexpected, routes)
 }
 
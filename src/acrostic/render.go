@@ -0,0 +1,283 @@
+package acrostic
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// RenderStyle : HTML, SVGで出力するときの見た目
+type RenderStyle struct {
+	// CellSize : 1文字分のマスの大きさ(px)
+	CellSize int
+	// FontFamily : フォント
+	FontFamily string
+	// Color : 文字の色
+	Color string
+	// Background : 背景の色
+	Background string
+	// HighlightColor : 縦読み列の文字の色
+	HighlightColor string
+	// HighlightBackground : 縦読み列の背景の色
+	HighlightBackground string
+	// HiddenColors : --multiのときの2つ目以降のキーワードの文字の色(順に使う)
+	HiddenColors []string
+	// ShowSurface : 元の文を下に表示する
+	ShowSurface bool
+	// Tategaki : 縦書き(右から左)にする
+	Tategaki bool
+	// Ruby : かなにした部分に元の表記をルビとして振る
+	Ruby bool
+}
+
+// NewRenderStyle : オプションからRenderStyleを作る
+func NewRenderStyle(o *Options) RenderStyle {
+	ret := RenderStyle{
+		CellSize:            o.RenderCellSize,
+		FontFamily:          "serif",
+		Color:               "#000",
+		Background:          "#fff",
+		HighlightColor:      o.RenderHighlightColor,
+		HighlightBackground: o.RenderHighlightBackground,
+		ShowSurface:         o.RenderShowSurface,
+		Tategaki:            o.Tategaki,
+		Ruby:                o.RenderRuby,
+		HiddenColors:        []string{"#06c", "#080", "#c60"},
+	}
+	if ret.CellSize <= 0 {
+		ret.CellSize = 32
+	}
+	if ret.HighlightColor == "" {
+		ret.HighlightColor = "#c00"
+	}
+	return ret
+}
+
+// renderHighlight : 縦読み列(斜め読みのときは斜めの並び)に含まれるマスかどうか
+func renderHighlight(r Result, row int, col int) bool {
+	return renderKeyword(r, row, col) >= 0
+}
+
+// renderKeyword : マスが何番目の隠れたキーワードの文字か(どれでもなければ-1)
+func renderKeyword(r Result, row int, col int) int {
+	return hiddenAt(resultHidden(r.Keyword, r.KeywordEnd, r.Hidden), r.Step, row, col)
+}
+
+// renderColumns : 表示する列数．ぶら下げた文字(--kinsoku-hanging)があれば行列の幅より多い
+func renderColumns(r Result) int {
+	ret := r.Width
+	for _, row := range matrixRows(r.Matrix) {
+		if ret < len(row) {
+			ret = len(row)
+		}
+	}
+	return ret
+}
+
+// renderKeywordClass : n番目(1から)のキーワードのCSSクラスの番号．HiddenColorsを順に使う
+func (s RenderStyle) renderKeywordClass(n int) int {
+	if len(s.HiddenColors) == 0 {
+		return 0
+	}
+	return (n-1)%len(s.HiddenColors) + 1
+}
+
+// keywordColor : i番目のキーワードの文字の色
+func (s RenderStyle) keywordColor(i int) string {
+	if i == 0 || len(s.HiddenColors) == 0 {
+		return s.HighlightColor
+	}
+	return s.HiddenColors[s.renderKeywordClass(i)-1]
+}
+
+// renderSurface : 表示用の元の文．改行の印は空白にする
+func renderSurface(r Result) string {
+	return strings.Replace(string(r.Surface), "\\n", " ", -1)
+}
+
+// RenderHTMLStyleSheet : RenderHTMLの出力に使うCSS
+func RenderHTMLStyleSheet(s RenderStyle) string {
+	ret := fmt.Sprintf(`.acrostic { display: inline-block; margin: 1em; }
+.acrostic table { border-collapse: collapse; font-family: %v; color: %v; background: %v; }
+.acrostic td { width: %vpx; height: %vpx; padding: 0; text-align: center; font-size: %vpx; }
+.acrostic .acrostic-keyword { color: %v; background: %v; font-weight: bold; }
+.acrostic td.acrostic-ruby { text-align: left; }
+.acrostic td.acrostic-ruby span { display: inline-block; width: %vpx; text-align: center; }
+.acrostic rt { font-size: 0.4em; font-weight: normal; }
+.acrostic figcaption { margin-top: 0.5em; font-size: 0.8em; }
+.acrostic-tategaki table { writing-mode: vertical-rl; }
+.acrostic-tategaki figcaption { writing-mode: horizontal-tb; }
+`,
+		s.FontFamily, s.Color, s.Background,
+		s.CellSize, s.CellSize, s.CellSize*3/4,
+		s.HighlightColor, renderOr(s.HighlightBackground, "transparent"), s.CellSize)
+	for i := range s.HiddenColors {
+		ret += fmt.Sprintf(".acrostic .acrostic-keyword-%v { color: %v; }\n", i+1, s.HiddenColors[i])
+	}
+	return ret
+}
+
+// RenderHTML : 結果ひとつをHTMLの断片(figure)として書き出す
+// スタイルはRenderHTMLStyleSheetで与える
+func RenderHTML(w io.Writer, r Result, s RenderStyle) error {
+	var b bytes.Buffer
+	columns := renderColumns(r)
+	class := "acrostic"
+	if s.Tategaki {
+		// 表の行が右から左へ並ぶ列になり，句読点や長音符はブラウザが縦書き用の字形にする
+		class += " acrostic-tategaki"
+	}
+	b.WriteString(fmt.Sprintf("<figure class=\"%v\" data-keyword=\"%v\" data-width=\"%v\">\n<table>\n",
+		class, html.EscapeString(string(r.Keyword)), r.Width))
+	var spans [][]rubySpan
+	if s.Ruby && len(r.Ruby) > 0 {
+		spans = rubyLines(r)
+	}
+	for ri, row := range matrixRows(r.Matrix) {
+		b.WriteString("<tr>")
+		for ci := 0; ci < columns; ci++ {
+			if sp := rubySpanAt(spans, ri, ci); sp != nil {
+				// ルビを振るかなは1つのマスにまとめ，1文字ずつマスの幅に並べる
+				b.WriteString(fmt.Sprintf("<td colspan=\"%v\" class=\"acrostic-ruby\"><ruby>", len(sp.Base)))
+				for i := range sp.Base {
+					b.WriteString(s.renderHTMLCell("span", r, ri, ci+i, html.EscapeString(string(sp.Base[i]))))
+				}
+				b.WriteString("<rt>" + html.EscapeString(string(sp.Ruby)) + "</rt></ruby></td>")
+				ci += len(sp.Base) - 1
+				continue
+			}
+			c := ""
+			if ci < len(row) {
+				c = html.EscapeString(string(row[ci]))
+			}
+			b.WriteString(s.renderHTMLCell("td", r, ri, ci, c))
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</table>\n")
+	if s.ShowSurface {
+		b.WriteString("<figcaption>" + html.EscapeString(renderSurface(r)) + "</figcaption>\n")
+	}
+	b.WriteString("</figure>\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// renderHTMLCell : マスひとつをtagで囲む．キーワードの文字にはクラスを付ける
+func (s RenderStyle) renderHTMLCell(tag string, r Result, ri int, ci int, c string) string {
+	if k := renderKeyword(r, ri, ci); k > 0 && s.renderKeywordClass(k) > 0 {
+		return fmt.Sprintf("<%v class=\"acrostic-keyword acrostic-keyword-%v\">%v</%v>",
+			tag, s.renderKeywordClass(k), c, tag)
+	} else if k >= 0 {
+		return "<" + tag + " class=\"acrostic-keyword\">" + c + "</" + tag + ">"
+	}
+	return "<" + tag + ">" + c + "</" + tag + ">"
+}
+
+// rubySpanAt : ri行目のci列から始まる，ルビを振るかなの並び(なければnil)
+func rubySpanAt(spans [][]rubySpan, ri int, ci int) *rubySpan {
+	if ri >= len(spans) {
+		return nil
+	}
+	for i := range spans[ri] {
+		if spans[ri][i].Col == ci && spans[ri][i].Ruby != nil {
+			return &spans[ri][i]
+		}
+	}
+	return nil
+}
+
+// RenderHTMLDocument : 結果をまとめてHTML文書として書き出す
+func RenderHTMLDocument(w io.Writer, results []Result, s RenderStyle) error {
+	_, err := io.WriteString(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n"+
+		"<title>acrostic</title>\n<style>\n"+RenderHTMLStyleSheet(s)+"</style>\n</head>\n<body>\n")
+	if err != nil {
+		return err
+	}
+	for i := range results {
+		err = RenderHTML(w, results[i], s)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = io.WriteString(w, "</body>\n</html>\n")
+	return err
+}
+
+// renderSVGGroup : 結果ひとつをSVGのgとして書き出す
+// return: 高さ(px)
+func renderSVGGroup(b *bytes.Buffer, r Result, s RenderStyle, y int) int {
+	cells := matrixRows(r.Matrix)
+	size := s.CellSize
+	b.WriteString(fmt.Sprintf("<g class=\"acrostic\" transform=\"translate(0,%v)\">\n", y))
+	for ri, row := range cells {
+		for ci := range row {
+			if renderHighlight(r, ri, ci) && s.HighlightBackground != "" {
+				b.WriteString(fmt.Sprintf("<rect x=\"%v\" y=\"%v\" width=\"%v\" height=\"%v\" fill=\"%v\"/>\n",
+					ci*size, ri*size, size, size, html.EscapeString(s.HighlightBackground)))
+			}
+		}
+	}
+	for ri, row := range cells {
+		for ci, c := range row {
+			fill := s.Color
+			weight := "normal"
+			if k := renderKeyword(r, ri, ci); k >= 0 {
+				fill = s.keywordColor(k)
+				weight = "bold"
+			}
+			b.WriteString(fmt.Sprintf("<text x=\"%v\" y=\"%v\" fill=\"%v\" font-weight=\"%v\">%v</text>\n",
+				ci*size+size/2, ri*size+size/2, html.EscapeString(fill), weight,
+				html.EscapeString(string(c))))
+		}
+	}
+	height := len(cells) * size
+	if s.ShowSurface {
+		b.WriteString(fmt.Sprintf("<text x=\"0\" y=\"%v\" fill=\"%v\" font-size=\"%v\" text-anchor=\"start\">%v</text>\n",
+			height+size/2, html.EscapeString(s.Color), size/2, html.EscapeString(renderSurface(r))))
+		height += size
+	}
+	b.WriteString("</g>\n")
+	return height
+}
+
+// RenderSVG : 結果ひとつをSVG文書として書き出す
+func RenderSVG(w io.Writer, r Result, s RenderStyle) error {
+	return RenderSVGDocument(w, []Result{r}, s)
+}
+
+// RenderSVGDocument : 結果を縦に並べてひとつのSVG文書として書き出す
+func RenderSVGDocument(w io.Writer, results []Result, s RenderStyle) error {
+	var body bytes.Buffer
+	height := 0
+	width := 0
+	for i := range results {
+		height += renderSVGGroup(&body, results[i], s, height)
+		if width < renderColumns(results[i])*s.CellSize {
+			width = renderColumns(results[i]) * s.CellSize
+		}
+		if i+1 < len(results) {
+			// 結果の間をあける
+			height += s.CellSize
+		}
+	}
+	var b bytes.Buffer
+	b.WriteString(fmt.Sprintf("<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%v\" height=\"%v\" viewBox=\"0 0 %v %v\">\n",
+		width, height, width, height))
+	b.WriteString(fmt.Sprintf("<rect width=\"100%%\" height=\"100%%\" fill=\"%v\"/>\n", html.EscapeString(s.Background)))
+	b.WriteString(fmt.Sprintf("<g font-family=\"%v\" font-size=\"%v\" text-anchor=\"middle\" dominant-baseline=\"central\">\n",
+		html.EscapeString(s.FontFamily), s.CellSize*3/4))
+	b.Write(body.Bytes())
+	b.WriteString("</g>\n</svg>\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func renderOr(s string, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,60 @@
+package acrostic
+
+import "strings"
+
+// tategakiForms : 縦書きで向きや位置が変わる文字と，その縦書き用の字形
+var tategakiForms = map[rune]rune{
+	'、': '︑', '。': '︒', '，': '︐',
+	'：': '︓', '；': '︔', '！': '︕', '？': '︖',
+	'ー': '︱', '－': '︱', '―': '︱',
+	'…': '︙', '‥': '︰',
+	'「': '﹁', '」': '﹂', '『': '﹃', '』': '﹄',
+	'（': '︵', '）': '︶', '｛': '︷', '｝': '︸',
+	'〔': '︹', '〕': '︺', '【': '︻', '】': '︼',
+	'《': '︽', '》': '︾', '〈': '︿', '〉': '﹀',
+	'［': '﹇', '］': '﹈',
+}
+
+// TategakiRune : 縦書きで表示する文字．句読点，括弧，長音符は縦書き用の字形にする
+func TategakiRune(c rune) rune {
+	if v, ok := tategakiForms[c]; ok {
+		return v
+	}
+	return c
+}
+
+// tategakiCell : 縦書きのi行目，j文字目に来る，横書きの行列のマス(行，列)
+// 横書きの行は右から左へ並ぶ列になるので，rows行の行列の最後の行が左端になる
+func tategakiCell(rows int, i int, j int) (int, int) {
+	return rows - 1 - j, i
+}
+
+// TategakiText : 行列を縦書き(右から左)のテキストにする
+// 横書きのi列目が縦書きのi行目になるので，縦読みのキーワードは1行に横に並ぶ．
+// cell: 横書きの行列のマス(行，列)の文字を表示する文字列にする
+func TategakiText(matrix [][]rune, cell func(c rune, row int, col int) string) string {
+	cells := matrixRows(matrix)
+	columns := 0
+	for _, row := range cells {
+		if columns < len(row) {
+			columns = len(row)
+		}
+	}
+	var b strings.Builder
+	for i := 0; i < columns; i++ {
+		line := ""
+		blank := ""
+		for j := range cells {
+			row, col := tategakiCell(len(cells), i, j)
+			if len(cells[row]) <= col {
+				// 空のマスは，後ろに文字が続くときだけ全角の空白で埋める
+				blank += "　"
+				continue
+			}
+			line += blank + cell(TategakiRune(cells[row][col]), row, col)
+			blank = ""
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
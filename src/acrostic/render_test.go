@@ -0,0 +1,113 @@
+package acrostic
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func tRenderResult() Result {
+	return Result{
+		Keyword: []rune("みかん"),
+		Width:   3,
+		Surface: []rune("あみい\\nうかえ<おんか"),
+		Matrix: [][]rune{
+			[]rune("あみい"),
+			[]rune("うかえ"),
+			[]rune{'<', 'ん', 0},
+		},
+		KeywordEnd: []int{2, 1},
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	s := NewRenderStyle(&Options{RenderShowSurface: true})
+	b := new(bytes.Buffer)
+	err := RenderHTML(b, tRenderResult(), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	if n := strings.Count(out, `<td class="acrostic-keyword">`); n != 3 {
+		t.Errorf("want 3 highlighted cells, but returned %v: %v", n, out)
+	}
+	for _, want := range []string{
+		`<td class="acrostic-keyword">ん</td>`,
+		`<td>&lt;</td>`,
+		`<td></td>`,
+		`<figcaption>あみい うかえ&lt;おんか</figcaption>`,
+	} {
+		if strings.Contains(out, want) == false {
+			t.Errorf("want %v in %v", want, out)
+		}
+	}
+}
+
+func TestRenderSVG(t *testing.T) {
+	s := NewRenderStyle(&Options{RenderCellSize: 10, RenderHighlightBackground: "#fee"})
+	b := new(bytes.Buffer)
+	err := RenderSVGDocument(b, []Result{tRenderResult(), tRenderResult()}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	// 3行 + 間 + 3行
+	if strings.Contains(out, `width="30" height="70"`) == false {
+		t.Errorf("unexpected svg size: %v", out)
+	}
+	if n := strings.Count(out, `fill="#fee"`); n != 6 {
+		t.Errorf("want 6 highlighted rects, but returned %v", n)
+	}
+	if strings.Contains(out, `font-weight="bold">か</text>`) == false {
+		t.Errorf("want highlighted か: %v", out)
+	}
+	if strings.Contains(out, "figcaption") || strings.Contains(out, "おんか") {
+		t.Errorf("surface should not be shown")
+	}
+}
+
+func TestRenderHighlightDiagonal(t *testing.T) {
+	r := Result{
+		Keyword:    []rune("みかん"),
+		Width:      3,
+		Matrix:     [][]rune{[]rune("みあい"), []rune("うかえ"), []rune("おえん")},
+		KeywordEnd: []int{2, 2},
+		Step:       1,
+	}
+	for row := range r.Matrix {
+		for col := range r.Matrix[row] {
+			if want := row == col; renderHighlight(r, row, col) != want {
+				t.Errorf("renderHighlight(%v, %v) want %v", row, col, want)
+			}
+		}
+	}
+}
+
+func TestTategakiText(t *testing.T) {
+	r := tRenderResult()
+	r.Matrix[1] = []rune("ーか」")
+	out := TategakiText(r.Matrix, func(c rune, row int, col int) string {
+		if renderHighlight(r, row, col) {
+			return "[" + string(c) + "]"
+		}
+		return string(c)
+	})
+	want := "<︱あ\n[ん][か][み]\n　﹂い\n"
+	if out != want {
+		t.Errorf("want %q, but returned %q", want, out)
+	}
+}
+
+func TestRenderHTMLTategaki(t *testing.T) {
+	b := new(bytes.Buffer)
+	err := RenderHTML(b, tRenderResult(), NewRenderStyle(&Options{Tategaki: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), `<figure class="acrostic acrostic-tategaki"`) == false {
+		t.Errorf("want tategaki class: %v", b.String())
+	}
+	if strings.Contains(RenderHTMLStyleSheet(NewRenderStyle(&Options{})), "writing-mode: vertical-rl") == false {
+		t.Errorf("want vertical-rl in the style sheet")
+	}
+}
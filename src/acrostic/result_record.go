@@ -0,0 +1,113 @@
+package acrostic
+
+// ResultRecord : --format json, jsonlで出力する結果ひとつ分
+type ResultRecord struct {
+	// Keyword : キーワード
+	Keyword string `json:"keyword"`
+	// KeywordIndex : キーワードの番号
+	KeywordIndex int `json:"keyword_index"`
+	// Width : 行の幅
+	Width int `json:"width"`
+	// Surface : 文パターンの表層
+	Surface string `json:"surface"`
+	// PatternIndex : 文パターンの番号
+	PatternIndex int `json:"pattern_index"`
+	// Rows : 行列の各行（空白埋めや色はつけない）
+	Rows []string `json:"rows"`
+	// KeywordColumn : 縦読み列の位置
+	KeywordColumn ResultKeywordColumn `json:"keyword_column"`
+	// PatternStack : 進めたパターンのスタック
+	PatternStack []int `json:"pattern_stack"`
+	// BranchStack : Bパターンに進んだかどうか
+	BranchStack []int `json:"branch_stack"`
+	// Score : 自然さのスコア
+	Score float64 `json:"score"`
+	// HiddenKeywords : --multiのときの隠れたキーワードごとの位置(先頭はKeywordColumnと同じ)
+	HiddenKeywords []ResultKeywordColumn `json:"hidden_keywords,omitempty"`
+	// Ruby : かなにした部分と元の表記
+	Ruby []ResultRuby `json:"ruby,omitempty"`
+}
+
+// ResultRuby : かなにした部分ひとつ
+type ResultRuby struct {
+	// Start : Rowsをつなげたときの，かなの先頭の文字の位置
+	Start int `json:"start"`
+	// Length : かなの文字数
+	Length int `json:"length"`
+	// Text : 元の表記
+	Text string `json:"text"`
+}
+
+// ResultKeywordColumn : 縦読み列の位置．行は0から数える
+type ResultKeywordColumn struct {
+	// Keyword : キーワード(HiddenKeywordsのときだけ)
+	Keyword string `json:"keyword,omitempty"`
+	// Column : 列(斜め読みのときは末尾の文字の列)
+	Column int `json:"column"`
+	// StartColumn : キーワードの先頭の文字の列(縦読みならばColumnと同じ)
+	StartColumn int `json:"start_column"`
+	// Step : 1行ごとにずれる列数(縦読みは0，diagonal-leftは-1，diagonal-rightは1)
+	Step int `json:"step"`
+	// StartRow : キーワードの先頭の行
+	StartRow int `json:"start_row"`
+	// EndRow : キーワードの末尾の行(KeywordEnd[0])
+	EndRow int `json:"end_row"`
+}
+
+// NewResultRecord : ResultからResultRecordを作成する
+func NewResultRecord(r Result) ResultRecord {
+	ret := ResultRecord{
+		Keyword:      string(r.Keyword),
+		KeywordIndex: r.KeywordNumber,
+		Width:        r.Width,
+		Surface:      string(r.Surface),
+		PatternIndex: r.PatternNumber,
+		Rows:         make([]string, 0, len(r.Matrix)),
+		PatternStack: r.PatternStack,
+		BranchStack:  r.BranchStack,
+		Score:        r.Score,
+	}
+	for _, row := range matrixRows(r.Matrix) {
+		ret.Rows = append(ret.Rows, string(row))
+	}
+	if len(r.KeywordEnd) == 2 {
+		ret.KeywordColumn = newResultKeywordColumn(r.Keyword, r.KeywordEnd, r.Step)
+	}
+	for _, h := range r.Hidden {
+		c := newResultKeywordColumn(h.Keyword, h.KeywordEnd, r.Step)
+		c.Keyword = string(h.Keyword)
+		ret.HiddenKeywords = append(ret.HiddenKeywords, c)
+	}
+	for _, r := range r.Ruby {
+		ret.Ruby = append(ret.Ruby, ResultRuby{Start: r.Start, Length: r.Length, Text: string(r.Text)})
+	}
+	return ret
+}
+
+// newResultKeywordColumn : 末尾の文字の位置keywordendからキーワードの位置を作る
+func newResultKeywordColumn(keyword []rune, keywordend []int, step int) ResultKeywordColumn {
+	start := keywordend[0] - len(keyword) + 1
+	return ResultKeywordColumn{
+		Column:      keywordend[1],
+		StartColumn: keywordColumnAt(keywordend, step, start),
+		Step:        step,
+		StartRow:    start,
+		EndRow:      keywordend[0],
+	}
+}
+
+// matrixRows : 行列の各行から，0以降の埋まっていない部分を除く
+func matrixRows(matrix [][]rune) [][]rune {
+	ret := make([][]rune, 0, len(matrix))
+	for _, row := range matrix {
+		line := make([]rune, 0, len(row))
+		for _, c := range row {
+			if c == 0 {
+				break
+			}
+			line = append(line, c)
+		}
+		ret = append(ret, line)
+	}
+	return ret
+}
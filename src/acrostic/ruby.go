@@ -0,0 +1,163 @@
+package acrostic
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Ruby : 結果のうち，漢字をかなに書き換えた部分と元の表記
+type Ruby struct {
+	// Start : 行列を行ごとに読んだときの，かなの先頭の文字の位置
+	Start int
+	// Length : かなの文字数
+	Length int
+	// Text : 元の表記．かなの上にルビとして振る
+	Text []rune
+}
+
+// newPatternRuby : 元の表記baseをかなkanaにしたときのルビ．startはパターンの中でのkanaの位置
+// 送りがななど，前後の同じ文字にはルビを振らない．書き換えていなければnil
+func newPatternRuby(start int, kana []rune, base []rune) []Ruby {
+	p := 0
+	for p < len(kana) && p < len(base) && kana[p] == base[p] {
+		p++
+	}
+	q := 0
+	for q < len(kana)-p && q < len(base)-p && kana[len(kana)-1-q] == base[len(base)-1-q] {
+		q++
+	}
+	if p+q >= len(kana) || p+q >= len(base) {
+		return nil
+	}
+	return []Ruby{Ruby{
+		Start:  start + p,
+		Length: len(kana) - p - q,
+		Text:   append([]rune(nil), base[p:len(base)-q]...),
+	}}
+}
+
+// RubyPattern : 基本句列とそれぞれで選んだパターンの番号から，結果のルビを集める
+func RubyPattern(bps []BasicPhrase, stack []int) []Ruby {
+	var ret []Ruby
+	offset := 0
+	for i := range stack {
+		if i >= len(bps) {
+			break
+		}
+		for _, r := range bps[i].Source(stack[i]).Ruby {
+			r.Start += offset
+			ret = append(ret, r)
+		}
+		if stack[i] < len(bps[i].Pattern) {
+			offset += len(bps[i].Pattern[stack[i]])
+		}
+	}
+	return ret
+}
+
+// rubySpan : 行の中で続けて書く文字の並び
+type rubySpan struct {
+	// Col : 先頭の文字の列
+	Col int
+	// Base : 行列の文字
+	Base []rune
+	// Ruby : 振るルビ(なければnil)
+	Ruby []rune
+}
+
+// rubyLines : 行ごとに，ルビを振る文字の並びとそれ以外の並びに分ける
+// 行をまたぐかなは，先頭の行の部分にだけルビを振る
+func rubyLines(r Result) [][]rubySpan {
+	rows := matrixRows(r.Matrix)
+	ret := make([][]rubySpan, len(rows))
+	pos := 0
+	ri := 0
+	for row := range rows {
+		spans := make([]rubySpan, 0)
+		for col, c := range rows[row] {
+			for ri < len(r.Ruby) && r.Ruby[ri].Start+r.Ruby[ri].Length <= pos {
+				ri++
+			}
+			in := ri < len(r.Ruby) && r.Ruby[ri].Start <= pos
+			n := len(spans)
+			switch {
+			case in && pos == r.Ruby[ri].Start:
+				spans = append(spans, rubySpan{Col: col, Ruby: r.Ruby[ri].Text})
+			case in && (n == 0 || spans[n-1].Ruby == nil):
+				// 前の行から続くかな
+				spans = append(spans, rubySpan{Col: col, Ruby: []rune{}})
+			case in == false && (n == 0 || spans[n-1].Ruby != nil):
+				spans = append(spans, rubySpan{Col: col})
+			}
+			spans[len(spans)-1].Base = append(spans[len(spans)-1].Base, c)
+			pos++
+		}
+		for i := range spans {
+			if spans[i].Ruby != nil && len(spans[i].Ruby) == 0 {
+				spans[i].Ruby = nil
+			}
+		}
+		ret[row] = spans
+	}
+	return ret
+}
+
+// RenderAozora : 結果を青空文庫の注記(｜かな《漢字》)でルビを振ったテキストとして書き出す
+func RenderAozora(w io.Writer, results []Result) error {
+	var b bytes.Buffer
+	for i, r := range results {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		for _, spans := range rubyLines(r) {
+			for _, s := range spans {
+				if s.Ruby != nil {
+					b.WriteString("｜" + string(s.Base) + "《" + string(s.Ruby) + "》")
+				} else {
+					b.WriteString(string(s.Base))
+				}
+			}
+			b.WriteString("\n")
+		}
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// latexEscaper : LaTeXで特別な意味を持つ文字
+var latexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`, `{`, `\{`, `}`, `\}`, `$`, `\$`, `&`, `\&`,
+	`#`, `\#`, `%`, `\%`, `_`, `\_`, `~`, `\textasciitilde{}`, `^`, `\textasciicircum{}`)
+
+// RenderLaTeX : 結果を\rubyでルビを振ったLaTeX文書(LuaLaTeX, luatexja-ruby)として書き出す
+func RenderLaTeX(w io.Writer, results []Result) error {
+	var b bytes.Buffer
+	b.WriteString("\\documentclass{ltjsarticle}\n\\usepackage{luatexja-ruby}\n\\begin{document}\n")
+	for i, r := range results {
+		if i > 0 {
+			b.WriteString("\\bigskip\n")
+		}
+		b.WriteString(fmt.Sprintf("%% %v (%v)\n\\noindent\n", latexEscaper.Replace(string(r.Keyword)), r.Width))
+		lines := rubyLines(r)
+		for li, spans := range lines {
+			for _, s := range spans {
+				if s.Ruby != nil {
+					b.WriteString("\\ruby{" + latexEscaper.Replace(string(s.Base)) + "}{" +
+						latexEscaper.Replace(string(s.Ruby)) + "}")
+				} else {
+					b.WriteString(latexEscaper.Replace(string(s.Base)))
+				}
+			}
+			if li+1 < len(lines) {
+				b.WriteString("\\\\")
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\\par\n")
+	}
+	b.WriteString("\\end{document}\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
@@ -0,0 +1,122 @@
+package acrostic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNewPatternRuby(t *testing.T) {
+	for _, c := range []struct {
+		kana string
+		base string
+		want string
+	}{
+		{"ひんしつ", "品質", "0 4 品質"},
+		{"たべる", "食べる", "0 1 食"},
+		{"おみやげ", "お土産", "1 3 土産"},
+		{"みかん", "みかん", ""},
+	} {
+		got := ""
+		for _, r := range newPatternRuby(0, []rune(c.kana), []rune(c.base)) {
+			got = fmt.Sprintf("%v %v %v", r.Start, r.Length, string(r.Text))
+		}
+		if got != c.want {
+			t.Errorf("%v %v: want %q, but returned %q", c.kana, c.base, c.want, got)
+		}
+	}
+}
+
+func TestAppendPatternRuby(t *testing.T) {
+	bp := &BasicPhrase{
+		Options:          tAnalyzerOptions(),
+		SurfaceOrder:     []Part{NounPart, ParticlePart},
+		ParticleSurface:  [][]rune{[]rune("が")},
+		PatternLengthMap: map[int]bool{},
+	}
+	err := bp.AppendPattern([]rune("ひんしつ"),
+		PatternSource{Kind: PatternSurface, Kana: true, Base: []rune("品質")}, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bps := []BasicPhrase{tNewBasicPhrases("あの")[0], *bp}
+	bps[0].Pattern = [][]rune{[]rune("あの")}
+	ruby := RubyPattern(bps, []int{0, 0})
+	if len(ruby) != 1 || ruby[0].Start != 2 || ruby[0].Length != 4 || string(ruby[0].Text) != "品質" {
+		t.Errorf("want 品質 over ひんしつ at 2, but returned %v", ruby)
+	}
+}
+
+// tRubyResult : 2行目から3行目にまたがる「ひんしつ」に「品質」を振る結果
+func tRubyResult() Result {
+	return Result{
+		Keyword:    []rune("みかん"),
+		Width:      3,
+		Matrix:     [][]rune{[]rune("あみい"), []rune("うかひ"), []rune("んしつ")},
+		KeywordEnd: []int{2, 1},
+		Ruby:       []Ruby{Ruby{Start: 5, Length: 4, Text: []rune("品質")}},
+	}
+}
+
+func TestRenderAozora(t *testing.T) {
+	b := new(bytes.Buffer)
+	err := RenderAozora(b, []Result{tRubyResult()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "あみい\nうか｜ひ《品質》\nんしつ\n"
+	if b.String() != want {
+		t.Errorf("want %q, but returned %q", want, b.String())
+	}
+}
+
+func TestRenderLaTeX(t *testing.T) {
+	r := tRubyResult()
+	r.Matrix[0] = []rune("あ%い")
+	b := new(bytes.Buffer)
+	err := RenderLaTeX(b, []Result{r})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	if strings.Contains(out, "あ\\%い\\\\\nうか\\ruby{ひ}{品質}\\\\\nんしつ\n") == false {
+		t.Errorf("unexpected latex: %v", out)
+	}
+}
+
+func TestRenderHTMLRuby(t *testing.T) {
+	r := tRubyResult()
+	r.Ruby[0].Start = 3
+	r.Ruby[0].Length = 2
+	b := new(bytes.Buffer)
+	err := RenderHTML(b, r, NewRenderStyle(&Options{RenderRuby: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<tr><td colspan="2" class="acrostic-ruby"><ruby><span>う</span>` +
+		`<span class="acrostic-keyword">か</span><rt>品質</rt></ruby></td><td>ひ</td></tr>`
+	if strings.Contains(b.String(), want) == false {
+		t.Errorf("want %v, but returned %v", want, b.String())
+	}
+}
+
+func TestResultRecordRuby(t *testing.T) {
+	data, err := json.Marshal(NewResultRecord(tRubyResult()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"ruby":[{"start":5,"length":4,"text":"品質"}]`) == false {
+		t.Errorf("want ruby in the record: %s", data)
+	}
+	var rec ResultRecord
+	err = json.Unmarshal(data, &rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewResultFromRecord(rec)
+	if len(r.Ruby) != 1 || string(r.Ruby[0].Text) != "品質" {
+		t.Errorf("want ruby restored, but returned %v", r.Ruby)
+	}
+}
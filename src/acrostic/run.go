@@ -0,0 +1,169 @@
+package acrostic
+
+import (
+	"context"
+	"errors"
+)
+
+// Request : Runに渡す入力
+type Request struct {
+	// Options : オプション（Runの中ではコピーして使う）
+	Options *Options
+	// Instance : 共通インスタンス（nilならば新しく作成して，終わったら閉じる）
+	Instance *Instance
+	// Text : テキスト
+	Text []rune
+	// Keywords : キーワード
+	Keywords [][]rune
+	// KeywordColumns : Options.Multiのときのキーワードごとの列(空ならばOptions.Column)
+	KeywordColumns []string
+	// Stats : nilでなければ，探索の統計を書き込む
+	Stats *RunStats
+}
+
+// RunStats : Runの探索の統計
+type RunStats struct {
+	// Nodes : 探索した状態の数
+	Nodes int64
+}
+
+// Result : 縦読み可能な文章ひとつ分の結果
+type Result struct {
+	// Keyword : キーワード
+	Keyword []rune
+	// KeywordNumber : キーワードの番号
+	KeywordNumber int
+	// Width : 行の幅
+	Width int
+	// PatternNumber : 文パターンの番号
+	PatternNumber int
+	// Surface : 文パターンの表層
+	Surface []rune
+	// Matrix : 行列
+	Matrix [][]rune
+	// KeywordEnd : 縦読み列の終端（行，列）
+	KeywordEnd []int
+	// PatternStack : 進めたパターンのスタック
+	PatternStack []int
+	// BranchStack : Bパターンに進んだかどうか
+	BranchStack []int
+	// Score : 自然さのスコア(大きいほど元の文に近い)
+	Score float64
+	// Step : キーワードが1行ごとにずれる列数(縦読みならば0)
+	Step int
+	// Hidden : Options.Multiのときの隠れたキーワード(先頭はKeyword, KeywordEndと同じ)
+	Hidden []HiddenKeyword
+	// Ruby : かなにした部分と元の表記
+	Ruby []Ruby
+}
+
+// NewResult : ArrangeMatrixResultからResultを作成する
+func NewResult(
+	keyword []rune,
+	kn int,
+	width int,
+	number int,
+	surface []rune,
+	r ArrangeMatrixResult) Result {
+	return Result{
+		Keyword:       keyword,
+		KeywordNumber: kn,
+		Width:         width,
+		PatternNumber: number,
+		Surface:       surface,
+		Matrix:        r.Matrix,
+		KeywordEnd:    r.KeywordEnd,
+		PatternStack:  r.PatternStack,
+		BranchStack:   r.BranchStack,
+		Score:         r.Score,
+		Step:          r.Step,
+		Hidden:        r.Hidden,
+		Ruby:          r.Ruby,
+	}
+}
+
+// ResultHandler : 結果を受け取る関数
+type ResultHandler func(Result) error
+
+// Run : 標準出力に書き出さずに，縦読み可能な文章を探索して返す
+// ctxが終了したり，Options.Timeout, Options.Deadlineを過ぎたりしたときは，
+// それまでに見つかった結果とエラーを返す
+func Run(ctx context.Context, req Request) ([]Result, error) {
+	ret := make([]Result, 0)
+	err := RunHandler(ctx, req, func(r Result) error {
+		ret = append(ret, r)
+		return nil
+	})
+	return ret, err
+}
+
+// RunHandler : Runと同じだが，結果が見つかるたびにhandlerに渡す
+// 同じInstanceを使って複数のgoroutineから呼んでもよい（解析はOptions.AnalyzerWorkersの数まで同時に行う）
+func RunHandler(ctx context.Context, req Request, handler ResultHandler) error {
+	if req.Options == nil {
+		return errors.New("require Options")
+	}
+	if len(req.Keywords) == 0 {
+		return errors.New("require keywords")
+	}
+	if len(req.Text) == 0 {
+		return errors.New("require text")
+	}
+	if req.Options.Width <= 0 {
+		return errors.New("require width")
+	}
+	o := *req.Options
+	o.Silent = true
+	o.Confirm = false
+	o.Interactive = false
+	o.KnpOnly = false
+	o.OutFileName = ""
+	o.Checkpoint = ""
+	o.Resume = ""
+	// ゼロ値のOptionsでも探索できるように，未指定の最大行と最大幅を自動にする
+	if o.Height <= 0 {
+		o.Height = -1
+	}
+	if o.MaxWidth < o.Width {
+		o.MaxWidth = o.Width
+	}
+
+	var err error
+	v := new(Acrostic)
+	v.Options = &o
+	v.Instance = req.Instance
+	if v.Instance == nil {
+		v.Instance, err = NewInstance(v.Options)
+		if err != nil {
+			return err
+		}
+		defer v.Instance.Close()
+	}
+	v.Keywords = req.Keywords
+	v.KeywordColumns = req.KeywordColumns
+	v.Text = [][]rune{[]rune(string(req.Text) + "\n")}
+	v.setHeight()
+
+	v.Handler = handler
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	if err = v.Analyze(); err != nil {
+		return err
+	}
+	err = v.GenerateContext(ctx)
+	if req.Stats != nil {
+		req.Stats.Nodes = v.Nodes()
+	}
+	if err != nil {
+		return err
+	}
+	if v.Truncated {
+		// 打ち切られたときは，それまでの結果とともに理由を返す
+		if err = ctx.Err(); err == nil {
+			err = context.DeadlineExceeded
+		}
+		return err
+	}
+	return nil
+}
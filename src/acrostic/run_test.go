@@ -0,0 +1,61 @@
+package acrostic
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRunZeroOptions(t *testing.T) {
+	// Height, MaxWidth, OutputEachPatternを指定しなくても探索できる
+	o := tAnalyzerOptions()
+	o.Width = 4
+	i := &Instance{
+		Analyzer: &tAnalyzer{Output: map[string]string{"みかんはあまい。": tKnpMikan}},
+	}
+	results, err := Run(context.Background(), Request{
+		Options:  o,
+		Instance: i,
+		Text:     []rune("みかんはあまい。"),
+		Keywords: [][]rune{[]rune("みあ")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 {
+		t.Fatal("want results")
+	}
+	for _, r := range results {
+		if r.Width != 4 || len(r.Surface) == 0 {
+			t.Errorf("unexpected result: %+v", r)
+		}
+	}
+	if o.Height != 0 || o.MaxWidth != 0 || o.OutputEachPattern {
+		t.Errorf("want options unchanged, but returned %+v", o)
+	}
+}
+
+func TestRunRequire(t *testing.T) {
+	text := []rune("みかんはあまい。")
+	keywords := [][]rune{[]rune("みあ")}
+	for _, c := range []struct {
+		name string
+		req  Request
+	}{
+		{"options", Request{Text: text, Keywords: keywords}},
+		{"keywords", Request{Options: &Options{Width: 4}, Text: text}},
+		{"text", Request{Options: &Options{Width: 4}, Keywords: keywords}},
+		{"width", Request{Options: &Options{}, Text: text, Keywords: keywords}},
+	} {
+		if _, err := Run(context.Background(), c.req); err == nil {
+			t.Errorf("%v: want error", c.name)
+		}
+	}
+}
+
+func TestInstanceClose(t *testing.T) {
+	// 作成していない解析ツールやデータベースは閉じない
+	i := &Instance{Analyzer: &tAnalyzer{}}
+	if err := i.Close(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,230 @@
+package acrostic
+
+import (
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PatternKind : BasicPhrase.Patternの由来
+type PatternKind int
+
+const (
+	// PatternSurface : 元の表層(またはそのかな)
+	PatternSurface PatternKind = iota
+	// PatternPolite : 丁寧語にした，または丁寧語をやめた語
+	PatternPolite
+	// PatternParaphrase : 言い換えデータベースによる言い換え
+	PatternParaphrase
+	// PatternSynonym : WordNetの同義語
+	PatternSynonym
+	// PatternHypernym : WordNetの上位語など，同義語以外のリンク
+	PatternHypernym
+)
+
+// PatternSource : パターンひとつの由来とスコア
+type PatternSource struct {
+	// Kind : 由来
+	Kind PatternKind
+	// Kana : かなで書いたかどうか
+	Kana bool
+	// Base : Kanaのとき，かなにする前の表記(ルビにする)
+	Base []rune
+	// Ruby : Kanaのとき，パターンの中でかなにした部分と元の表記(AppendPatternBaseで作る)
+	Ruby []Ruby
+	// Similarity : PatternHypernymのとき，元の語との近さ(0から1，1が同じ概念)
+	Similarity float64
+	// Score : UpdatePatternScoreで計算したスコア
+	Score float64
+}
+
+// ScoreWeights : スコアの重み
+type ScoreWeights struct {
+	// Kana : 漢字を含む語をかなにしたときの減点
+	Kana float64
+	// Polite : 丁寧語を変えたときの減点
+	Polite float64
+	// Paraphrase : 言い換えデータベースを使ったときの減点
+	Paraphrase float64
+	// Synonym : 同義語にしたときの減点
+	Synonym float64
+	// Hypernym : 上位語にしたときの減点(元の語から遠いほど最大2倍まで増える)
+	Hypernym float64
+	// Reorder : 基本句の順番を入れ替えたときの，転倒1つあたりの減点
+	Reorder float64
+	// Closeness : 元の表層との近さ(0から1)に掛ける加点
+	Closeness float64
+}
+
+// DefaultScoreWeights : 既定のスコアの重み
+var DefaultScoreWeights = ScoreWeights{
+	Kana:       1.0,
+	Polite:     0.5,
+	Paraphrase: 1.5,
+	Synonym:    1.0,
+	Hypernym:   1.0,
+	Reorder:    0.5,
+	Closeness:  1.0,
+}
+
+// synonymSource : 類語の由来を作る
+// --sort scoreのときは，上位語と元の語の近さをWordNetSynset.NearestSynsetで求める
+func (bp *BasicPhrase) synonymSource(s WordNetResult) PatternSource {
+	if s.Link == WNSynonym {
+		return PatternSource{Kind: PatternSynonym}
+	}
+	ret := PatternSource{Kind: PatternHypernym}
+	if bp.Options.Sort != "score" || bp.Instance.WordNet == nil {
+		return ret
+	}
+	ws := NewWordNetSynset(bp.Options, bp.Instance)
+	sr, err := ws.NearestSynset(bp.Origin, ToWordNetPart(bp.Part), s.Surface, s.Part)
+	if err != nil {
+		log.Debugf("synonymSource: %v-%v: %v", string(bp.Origin), string(s.Surface), err.Error())
+		return ret
+	}
+	for i := range sr {
+		v := 1.0
+		if sr[i].AStep != 0 || sr[i].BStep != 0 {
+			a := float64(sr[i].Approximation)
+			v = a / (1 + a)
+		}
+		if ret.Similarity < v {
+			ret.Similarity = v
+		}
+	}
+	return ret
+}
+
+// UpdatePatternScore : PatternSourceのScoreを計算する
+func (bp *BasicPhrase) UpdatePatternScore(w ScoreWeights) {
+	for len(bp.PatternSource) < len(bp.Pattern) {
+		bp.PatternSource = append(bp.PatternSource, PatternSource{})
+	}
+	kanji := HasOnlyKana(bp.Surface) == false
+	for i := range bp.Pattern {
+		s := &bp.PatternSource[i]
+		score := 0.0
+		if s.Kana && kanji {
+			score -= w.Kana
+		}
+		switch s.Kind {
+		case PatternPolite:
+			score -= w.Polite
+		case PatternParaphrase:
+			score -= w.Paraphrase
+		case PatternSynonym:
+			score -= w.Synonym
+		case PatternHypernym:
+			score -= w.Hypernym * (2 - s.Similarity)
+		}
+		// 同じ書き方(漢字またはかな)の元の表層と比べる
+		ref := bp.Surface
+		for j := range bp.Pattern {
+			if bp.PatternSource[j].Kind == PatternSurface && bp.PatternSource[j].Kana == s.Kana {
+				ref = bp.Pattern[j]
+				break
+			}
+		}
+		score += w.Closeness * closeness(bp.Pattern[i], ref)
+		s.Score = score
+	}
+}
+
+// closeness : 編集距離による近さ(0から1，1が同じ)
+func closeness(a []rune, b []rune) float64 {
+	n := len(a)
+	if n < len(b) {
+		n = len(b)
+	}
+	if n == 0 {
+		return 1
+	}
+	return 1 - float64(editDistance(a, b))/float64(n)
+}
+
+// editDistance : レーベンシュタイン距離
+func editDistance(a []rune, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			c := prev[j-1]
+			if a[i-1] != b[j-1] {
+				c++
+			}
+			if prev[j]+1 < c {
+				c = prev[j] + 1
+			}
+			if cur[j-1]+1 < c {
+				c = cur[j-1] + 1
+			}
+			cur[j] = c
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// ScorePattern : 基本句列とそれぞれで選んだパターンの番号から，結果のスコアを計算する
+// 大きいほど自然である
+func ScorePattern(bps []BasicPhrase, stack []int, w ScoreWeights) float64 {
+	score := 0.0
+	for i := range stack {
+		if i >= len(bps) {
+			break
+		}
+		score += bps[i].Source(stack[i]).Score
+	}
+	// 基本句の入れ替え：元の順番(ID)に対する転倒の数
+	inversions := 0
+	for i := range bps {
+		for j := i + 1; j < len(bps); j++ {
+			if bps[i].ID > bps[j].ID {
+				inversions++
+			}
+		}
+	}
+	return score - w.Reorder*float64(inversions)
+}
+
+// RankResults : Options.Sort, Options.Topにしたがって結果を並べ替え，上位だけを残す
+// 元の配列は変更しない．indexは並べ替えた結果の元の番号
+func RankResults(o *Options, ret []ArrangeMatrixResult) ([]ArrangeMatrixResult, []int) {
+	index := make([]int, len(ret))
+	for i := range index {
+		index[i] = i
+	}
+	if o.Sort == "score" {
+		sort.SliceStable(index, func(i, j int) bool {
+			return ret[index[i]].Score > ret[index[j]].Score
+		})
+	}
+	if o.Top > 0 && len(index) > o.Top {
+		index = index[:o.Top]
+	}
+	sorted := make([]ArrangeMatrixResult, len(index))
+	for i := range index {
+		sorted[i] = ret[index[i]]
+	}
+	return sorted, index
+}
+
+// RankResultList : すべてのキーワードと文パターンの結果をまとめて，RankResultsと同じように並べ替える
+func RankResultList(o *Options, ret []Result) []Result {
+	sorted := make([]Result, len(ret))
+	copy(sorted, ret)
+	if o.Sort == "score" {
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].Score > sorted[j].Score
+		})
+	}
+	if o.Top > 0 && len(sorted) > o.Top {
+		sorted = sorted[:o.Top]
+	}
+	return sorted
+}
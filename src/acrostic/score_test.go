@@ -0,0 +1,83 @@
+package acrostic
+
+import (
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		d    int
+	}{
+		{"", "", 0},
+		{"みかん", "みかん", 0},
+		{"みかん", "みかんは", 1},
+		{"蜜柑", "みかん", 3},
+		{"あまい", "あまくない", 2},
+	} {
+		if d := editDistance([]rune(c.a), []rune(c.b)); d != c.d {
+			t.Errorf("editDistance(%v, %v) want %v, but returned %v", c.a, c.b, c.d, d)
+		}
+	}
+}
+
+func TestUpdatePatternScore(t *testing.T) {
+	bp := &BasicPhrase{
+		Surface: []rune("蜜柑は"),
+		Pattern: [][]rune{
+			[]rune("蜜柑は"),
+			[]rune("みかんは"),
+			[]rune("柑橘は"),
+			[]rune("果物は"),
+		},
+		PatternSource: []PatternSource{
+			PatternSource{Kind: PatternSurface},
+			PatternSource{Kind: PatternSurface, Kana: true},
+			PatternSource{Kind: PatternSynonym},
+			PatternSource{Kind: PatternHypernym, Similarity: 0.5},
+		},
+	}
+	bp.UpdatePatternScore(DefaultScoreWeights)
+	for i := 1; i < len(bp.Pattern); i++ {
+		if bp.PatternSource[i-1].Score <= bp.PatternSource[i].Score {
+			t.Errorf("want %v (%v) > %v (%v)",
+				string(bp.Pattern[i-1]), bp.PatternSource[i-1].Score,
+				string(bp.Pattern[i]), bp.PatternSource[i].Score)
+		}
+	}
+	if bp.PatternSource[0].Score != DefaultScoreWeights.Closeness {
+		t.Errorf("want the original surface to score %v, but returned %v",
+			DefaultScoreWeights.Closeness, bp.PatternSource[0].Score)
+	}
+}
+
+func TestScorePatternReorder(t *testing.T) {
+	bps := []BasicPhrase{
+		BasicPhrase{ID: 0, PatternSource: []PatternSource{PatternSource{Score: 1}}},
+		BasicPhrase{ID: 1, PatternSource: []PatternSource{PatternSource{Score: 1}}},
+	}
+	inorder := ScorePattern(bps, []int{0, 0}, DefaultScoreWeights)
+	swapped := ScorePattern([]BasicPhrase{bps[1], bps[0]}, []int{0, 0}, DefaultScoreWeights)
+	if inorder != 2 || swapped != 2-DefaultScoreWeights.Reorder {
+		t.Errorf("want 2 and %v, but returned %v and %v", 2-DefaultScoreWeights.Reorder, inorder, swapped)
+	}
+}
+
+func TestRankResults(t *testing.T) {
+	ret := []ArrangeMatrixResult{
+		ArrangeMatrixResult{Score: 0.5},
+		ArrangeMatrixResult{Score: 2},
+		ArrangeMatrixResult{Score: 1},
+	}
+	sorted, index := RankResults(&Options{Sort: "score", Top: 2}, ret)
+	if len(sorted) != 2 || sorted[0].Score != 2 || sorted[1].Score != 1 {
+		t.Errorf("want scores [2 1], but returned %v", sorted)
+	}
+	if index[0] != 1 || index[1] != 2 {
+		t.Errorf("want index [1 2], but returned %v", index)
+	}
+	sorted, _ = RankResults(&Options{Top: 1}, ret)
+	if len(sorted) != 1 || sorted[0].Score != 0.5 {
+		t.Errorf("want the first result found, but returned %v", sorted)
+	}
+}
@@ -68,58 +68,20 @@ func NewSentence(o *Options, i *Instance, t []rune, newline bool, keywords [][]r
 	return ret
 }
 
-// Analyze : 解析する
+// Analyze : Instance.Analyzerで解析する
 func (s *Sentence) Analyze(begin int) (int, error) {
-	if s.Options.Mode != "" {
-		return 0, errors.New("not implemented")
+	if s.Instance.Analyzer == nil {
+		return 0, errors.New("Sentence.Analyze: analyzer is not initialized")
 	}
-	return s.AnalyzeJumanKnp(begin)
-}
-
-func (s *Sentence) Split(v []rune) [][][]rune {
-	lfToken := []rune("\n")
-	phraseToken := []rune("*")
-	eosToken := []rune("EOS")
-	t := runes.Split(v, lfToken)
-
-	ret := make([][][]rune, 0)
-	phrase := make([][]rune, 0)
-	enable := false
-	for i := 0; i < len(t); i++ {
-		if t[i][0] == phraseToken[0] {
-			if len(phrase) > 0 {
-				phrasec := make([][]rune, len(phrase))
-				for i := range phrase {
-					phrasec[i] = make([]rune, len(phrase[i]))
-					copy(phrasec[i], phrase[i])
-				}
-				phrase = phrase[:0]
-				ret = append(ret, phrasec)
-			}
-			enable = true
-		}
-		if enable {
-			if !runes.Compare(eosToken, t[i][0:3]) {
-				phrase = append(phrase, t[i])
-			}
-		}
-	}
-	if len(phrase) > 0 {
-		phrasec := make([][]rune, len(phrase))
-		for i := range phrase {
-			phrasec[i] = make([]rune, len(phrase[i]))
-			copy(phrasec[i], phrase[i])
-		}
-		ret = append(ret, phrasec)
+	array, err := s.Instance.Analyzer.Analyze(s.Text)
+	if err != nil {
+		return 0, err
 	}
-	return ret
+	return s.AnalyzePhrases(array, begin)
 }
 
-// AnalyzeJumanKnp : Juman and KNP で解析する
-func (s *Sentence) AnalyzeJumanKnp(begin int) (int, error) {
-	//log.Debugf("Sentence.AnalyzeJumanKnp: %v;", string(s.Text))
-	out := s.Instance.JumanKnp.Execute(s.Text, true)
-	array := s.Split(out)
+// AnalyzePhrases : 文節ごとに分割されたknp -tab形式の行から構造を作る
+func (s *Sentence) AnalyzePhrases(array [][][]rune, begin int) (int, error) {
 	for i := range array {
 		newline := i == 0 && s.NewLine
 		phrase := NewPhrase(s.Options, s.Instance, array[i], len(s.Phrases), newline, s.Keywords)
@@ -255,7 +217,10 @@ func (s *Sentence) AnalyzeJumanKnp(begin int) (int, error) {
 	log.Debugf("%v patterns found", len(s.Pattern.Orders))
 	log.Debugf("Sentence: change particle part order and append to CaseAnalysisPhrases")
 
-	s.MakeParallelPhrase()
+	err = s.MakeParallelPhrase()
+	if err != nil {
+		return 0, err
+	}
 	s.ParticleOrder()
 
 	caseAnalysisInvalidPatterns := make([][]int, 0, 10)
@@ -300,7 +265,8 @@ func (s *Sentence) AnalyzeJumanKnp(begin int) (int, error) {
 	return 0, nil
 }
 
-func (s *Sentence) MakeParallelPhrase() {
+// MakeParallelPhrase : 並列句を入れ替えた文節列を作る
+func (s *Sentence) MakeParallelPhrase() error {
 	log.Debugf("MakeParallelPhrase")
 	s.ParallelPhrases = make([][][]*Phrase, len(s.Pattern.SubPatterns))
 	for pati, pat := range s.Pattern.SubPatterns {
@@ -344,8 +310,14 @@ func (s *Sentence) MakeParallelPhrase() {
 								runes.CopyArray(orig.BasicPhrases[bpi].ParticleSurface)
 							t.BasicPhrases[bpi].AllParticleSurface =
 								runes.Copy(orig.BasicPhrases[bpi].AllParticleSurface)
-							t.BasicPhrases[bpi].UpdateSurface()
-							t.BasicPhrases[bpi].UpdatePattern()
+							err := t.BasicPhrases[bpi].UpdateSurface()
+							if err != nil {
+								return err
+							}
+							err = t.BasicPhrases[bpi].UpdatePattern()
+							if err != nil {
+								return err
+							}
 						}
 						//if tcount > origcount {
 						//	// 削除
@@ -385,6 +357,7 @@ func (s *Sentence) MakeParallelPhrase() {
 	//		log.Debugf("%v: %v", i, string(o))
 	//	}
 	//}
+	return nil
 }
 
 func (s *Sentence) ParticleOrder() {
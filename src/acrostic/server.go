@@ -0,0 +1,403 @@
+package acrostic
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ジョブの状態
+const (
+	// JobQueued : 実行待ち
+	JobQueued = "queued"
+	// JobRunning : 実行中
+	JobRunning = "running"
+	// JobDone : 終了
+	JobDone = "done"
+	// JobFailed : エラーで終了
+	JobFailed = "failed"
+	// JobCanceled : 取り消された
+	JobCanceled = "canceled"
+)
+
+// Server : acrostic serveで動くHTTPサーバ
+// Instance(解析ツールのパイプ，WordNetデータベース)をひとつだけ作って，すべてのジョブで使う．
+//
+// POST   /jobs             ジョブを作る(ServerRequest)
+// GET    /jobs/{id}        ジョブの状態と結果
+// GET    /jobs/{id}/events 結果をServer-Sent Eventsで受け取る
+// DELETE /jobs/{id}        ジョブを取り消す
+type Server struct {
+	Options  *Options
+	Instance *Instance
+	// Retention : 終了したジョブを保持する時間
+	Retention time.Duration
+
+	jobs      map[string]*ServerJob
+	mutex     sync.Mutex
+	semaphore chan struct{}
+}
+
+// ServerRequest : POST /jobsの本文
+type ServerRequest struct {
+	Text     string   `json:"text"`
+	Keywords []string `json:"keywords"`
+	// Width : 行の幅(0ならばサーバの--width)
+	Width int `json:"width"`
+	// MaxWidth : 行の最大幅(0ならばWidthと同じ)
+	MaxWidth int `json:"max_width"`
+	// Height : 最大行(0ならば自動)
+	Height int `json:"height"`
+	// One : ひとつ見つかったら終了する
+	One bool `json:"one"`
+	// Timeout : 探索の制限時間(例："30s")．サーバの--timeoutより長くはできない
+	Timeout string `json:"timeout"`
+}
+
+// ServerJob : ジョブ
+type ServerJob struct {
+	ID      string
+	Status  string
+	Err     error
+	Results []Result
+	// Truncated : 時間切れで探索を打ち切ったかどうか
+	Truncated bool
+	Created   time.Time
+	Finished  time.Time
+
+	mutex   sync.Mutex
+	changed chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// ServerJobStatus : GET /jobs/{id}の応答
+type ServerJobStatus struct {
+	ID        string         `json:"id"`
+	Status    string         `json:"status"`
+	Error     string         `json:"error,omitempty"`
+	Truncated bool           `json:"truncated"`
+	Created   time.Time      `json:"created"`
+	Count     int            `json:"count"`
+	Results   []ResultRecord `json:"results,omitempty"`
+}
+
+// NewServer : constructor
+// iがnilならば新しくInstanceを作成する
+func NewServer(o *Options, i *Instance) (*Server, error) {
+	var err error
+	ret := new(Server)
+	ret.Options = o
+	ret.Instance = i
+	if ret.Instance == nil {
+		ret.Instance, err = NewInstance(o)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ret.Retention = time.Hour
+	ret.jobs = map[string]*ServerJob{}
+	n := o.MaxJobs
+	if n <= 0 {
+		n = 1
+	}
+	ret.semaphore = make(chan struct{}, n)
+	return ret, nil
+}
+
+// ListenAndServe : Options.Listenで待ち受ける
+func (s *Server) ListenAndServe() error {
+	log.Infof("serve: listening on %v", s.Options.Listen)
+	return http.ListenAndServe(s.Options.Listen, s)
+}
+
+// ServeHTTP : http.Handlerの実装
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+	if parts[0] != "jobs" || len(parts) > 3 {
+		http.NotFound(w, r)
+		return
+	}
+	if len(parts) == 1 {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleCreate(w, r)
+		return
+	}
+	job := s.job(parts[1])
+	if job == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if len(parts) == 3 {
+		if parts[2] != "events" || r.Method != http.MethodGet {
+			http.NotFound(w, r)
+			return
+		}
+		s.handleEvents(w, r, job)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, job.status(true))
+	case http.MethodDelete:
+		job.cancel()
+		writeJSON(w, http.StatusOK, job.status(false))
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var sr ServerRequest
+	err := json.NewDecoder(r.Body).Decode(&sr)
+	if err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	req, err := s.request(sr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	job, err := s.Start(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, job.status(false))
+}
+
+// request : ServerRequestからジョブのRequestを作る
+func (s *Server) request(sr ServerRequest) (Request, error) {
+	if strings.TrimSpace(sr.Text) == "" {
+		return Request{}, errors.New("require text")
+	}
+	keywords := make([][]rune, 0, len(sr.Keywords))
+	for _, k := range sr.Keywords {
+		if k = strings.TrimSpace(k); k != "" {
+			keywords = append(keywords, []rune(k))
+		}
+	}
+	if len(keywords) == 0 {
+		return Request{}, errors.New("require keywords")
+	}
+	o := *s.Options
+	if sr.Width > 0 {
+		o.Width = sr.Width
+	}
+	o.MaxWidth = o.Width
+	if sr.MaxWidth > 0 {
+		o.MaxWidth = sr.MaxWidth
+	}
+	if o.MaxWidth < o.Width {
+		return Request{}, fmt.Errorf("max_width %v < width %v", o.MaxWidth, o.Width)
+	}
+	o.Height = -1
+	if sr.Height > 0 {
+		o.Height = sr.Height
+	}
+	o.One = sr.One
+	if sr.Timeout != "" {
+		t, err := time.ParseDuration(sr.Timeout)
+		if err != nil {
+			return Request{}, fmt.Errorf("timeout: %v", err.Error())
+		}
+		if t > 0 && (o.Timeout == 0 || t < o.Timeout) {
+			o.Timeout = t
+		}
+	}
+	return Request{
+		Options:  &o,
+		Instance: s.Instance,
+		Text:     []rune(sr.Text),
+		Keywords: keywords,
+	}, nil
+}
+
+// Start : ジョブを作って実行を始める．同時に実行するジョブの数はOptions.MaxJobsまで
+func (s *Server) Start(req Request) (*ServerJob, error) {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+	job := &ServerJob{
+		ID:      hex.EncodeToString(b),
+		Status:  JobQueued,
+		Results: make([]Result, 0),
+		Created: time.Now(),
+		changed: make(chan struct{}),
+	}
+	job.ctx, job.cancel = context.WithCancel(context.Background())
+
+	s.mutex.Lock()
+	s.cleanup()
+	s.jobs[job.ID] = job
+	s.mutex.Unlock()
+
+	go s.run(job, req)
+	return job, nil
+}
+
+func (s *Server) run(job *ServerJob, req Request) {
+	select {
+	case s.semaphore <- struct{}{}:
+	case <-job.ctx.Done():
+		job.finish(job.ctx.Err())
+		return
+	}
+	defer func() { <-s.semaphore }()
+	job.update(func() { job.Status = JobRunning })
+	log.Infof("serve: job %v started", job.ID)
+	err := RunHandler(job.ctx, req, job.add)
+	job.finish(err)
+	log.Infof("serve: job %v %v", job.ID, job.Status)
+}
+
+func (s *Server) job(id string) *ServerJob {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.jobs[id]
+}
+
+// cleanup : Retentionより前に終了したジョブを消す．s.mutexをロックしてから呼ぶこと
+func (s *Server) cleanup() {
+	for id, job := range s.jobs {
+		job.mutex.Lock()
+		old := job.Finished.IsZero() == false && time.Since(job.Finished) > s.Retention
+		job.mutex.Unlock()
+		if old {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// handleEvents : 結果をServer-Sent Eventsで送る
+// event: result  data: ResultRecord
+// event: done    data: ServerJobStatus(結果なし)
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request, job *ServerJob) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	sent := 0
+	for {
+		results, finished, changed := job.since(sent)
+		for i := range results {
+			b, err := json.Marshal(NewResultRecord(results[i]))
+			if err != nil {
+				log.Warnf("serve: %v", err.Error())
+				return
+			}
+			fmt.Fprintf(w, "id: %v\nevent: result\ndata: %s\n\n", sent+i, b)
+		}
+		sent += len(results)
+		if finished {
+			b, err := json.Marshal(job.status(false))
+			if err != nil {
+				log.Warnf("serve: %v", err.Error())
+				return
+			}
+			fmt.Fprintf(w, "event: done\ndata: %s\n\n", b)
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// update : ロックしてfを実行し，待っているクライアントに知らせる
+func (j *ServerJob) update(f func()) {
+	j.mutex.Lock()
+	f()
+	close(j.changed)
+	j.changed = make(chan struct{})
+	j.mutex.Unlock()
+}
+
+// add : ResultHandlerの実装
+func (j *ServerJob) add(r Result) error {
+	j.update(func() { j.Results = append(j.Results, r) })
+	return nil
+}
+
+func (j *ServerJob) finish(err error) {
+	j.update(func() {
+		j.Finished = time.Now()
+		j.Err = err
+		switch {
+		case err == nil:
+			j.Status = JobDone
+		case errors.Is(err, context.DeadlineExceeded):
+			j.Status = JobDone
+			j.Truncated = true
+			j.Err = nil
+		case errors.Is(err, context.Canceled):
+			j.Status = JobCanceled
+		default:
+			j.Status = JobFailed
+		}
+	})
+	j.cancel()
+}
+
+// since : n件目以降の結果，終了したかどうか，次に変化したときに閉じられるチャネル
+func (j *ServerJob) since(n int) ([]Result, bool, chan struct{}) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	finished := j.Finished.IsZero() == false
+	return j.Results[n:], finished, j.changed
+}
+
+func (j *ServerJob) status(results bool) ServerJobStatus {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	ret := ServerJobStatus{
+		ID:        j.ID,
+		Status:    j.Status,
+		Truncated: j.Truncated,
+		Created:   j.Created,
+		Count:     len(j.Results),
+	}
+	if j.Err != nil {
+		ret.Error = j.Err.Error()
+	}
+	if results {
+		ret.Results = make([]ResultRecord, len(j.Results))
+		for i := range j.Results {
+			ret.Results[i] = NewResultRecord(j.Results[i])
+		}
+	}
+	return ret
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Warnf("serve: %v", err.Error())
+	}
+}
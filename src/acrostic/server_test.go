@@ -0,0 +1,130 @@
+package acrostic
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func tServer(t *testing.T) *httptest.Server {
+	o := tAnalyzerOptions()
+	o.Width = 4
+	o.MaxJobs = 1
+	i := &Instance{
+		Analyzer: &tAnalyzer{Output: map[string]string{"みかんはあまい。": tKnpMikan}},
+	}
+	s, err := NewServer(o, i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewServer(s)
+}
+
+func TestServerEvents(t *testing.T) {
+	ts := tServer(t)
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL+"/jobs", "application/json",
+		strings.NewReader(`{"text": "みかんはあまい。", "keywords": ["みあ"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var job ServerJobStatus
+	err = json.NewDecoder(res.Body).Decode(&job)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusAccepted || job.ID == "" {
+		t.Fatalf("want 202 and job id, but returned %v %+v", res.StatusCode, job)
+	}
+
+	res, err = http.Get(ts.URL + "/jobs/" + job.ID + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("want text/event-stream, but returned %v", ct)
+	}
+	results := make([]ResultRecord, 0)
+	var done ServerJobStatus
+	event := ""
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data := []byte(strings.TrimPrefix(line, "data: "))
+			if event == "result" {
+				var r ResultRecord
+				if err = json.Unmarshal(data, &r); err != nil {
+					t.Fatal(err)
+				}
+				results = append(results, r)
+			} else if event == "done" {
+				if err = json.Unmarshal(data, &done); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	if done.Status != JobDone || done.Error != "" {
+		t.Errorf("want done, but returned %+v", done)
+	}
+	if len(results) == 0 || done.Count != len(results) {
+		t.Fatalf("want results, but returned %v (count %v)", len(results), done.Count)
+	}
+	if results[0].Keyword != "みあ" || results[0].Width != 4 {
+		t.Errorf("unexpected result %+v", results[0])
+	}
+
+	res, err = http.Get(ts.URL + "/jobs/" + job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status ServerJobStatus
+	err = json.NewDecoder(res.Body).Decode(&status)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Results) != len(results) {
+		t.Errorf("want %v results, but returned %v", len(results), len(status.Results))
+	}
+}
+
+func TestServerBadRequest(t *testing.T) {
+	ts := tServer(t)
+	defer ts.Close()
+
+	for _, body := range []string{
+		`{"text": "みかんはあまい。"}`,
+		`{"keywords": ["みあ"]}`,
+		`{"text": "みかんはあまい。", "keywords": ["みあ"], "width": 4, "max_width": 2}`,
+		`{"text": "みかんはあまい。", "keywords": ["みあ"], "timeout": "soon"}`,
+		`not json`,
+	} {
+		res, err := http.Post(ts.URL+"/jobs", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("%v: want 400, but returned %v", body, res.StatusCode)
+		}
+	}
+	res, err := http.Get(ts.URL + "/jobs/unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("want 404, but returned %v", res.StatusCode)
+	}
+}
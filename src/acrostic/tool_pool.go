@@ -0,0 +1,213 @@
+package acrostic
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/kr/pty"
+	log "github.com/sirupsen/logrus"
+)
+
+// ToolWorker : ptyで起動した外部コマンド(juman, knp, mecab, kakasi)ひとつ
+type ToolWorker struct {
+	// Command : sh -cに渡すコマンド
+	Command string
+	// Restarts : 再起動した回数
+	Restarts int
+
+	cmd    *exec.Cmd
+	pipe   *os.File
+	reader *bufio.Reader
+	exited chan struct{}
+}
+
+// start : コマンドを起動する
+func (w *ToolWorker) start() error {
+	w.cmd = exec.Command("sh", "-c", w.Command)
+	pipe, err := pty.Start(w.cmd)
+	if err != nil {
+		w.pipe = nil
+		return err
+	}
+	w.pipe = pipe
+	w.reader = bufio.NewReader(pipe)
+	exited := make(chan struct{})
+	w.exited = exited
+	cmd := w.cmd
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+	return nil
+}
+
+// Alive : コマンドが動いているかどうか
+func (w *ToolWorker) Alive() bool {
+	if w.pipe == nil {
+		return false
+	}
+	select {
+	case <-w.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// stop : コマンドを終了する．ptyを閉じると，パイプでつないだ子プロセスにもSIGHUPが送られる
+func (w *ToolWorker) stop() {
+	if w.pipe == nil {
+		return
+	}
+	w.pipe.Close()
+	if w.cmd.Process != nil {
+		w.cmd.Process.Kill()
+	}
+	w.pipe = nil
+}
+
+// restart : コマンドを起動し直す
+func (w *ToolWorker) restart() error {
+	w.stop()
+	w.Restarts++
+	return w.start()
+}
+
+// ToolPool : 同じ外部コマンドを複数起動しておき，空いているものに要求を渡す
+// 各要求にはTimeoutがあり，時間切れになったり，コマンドが終了していたりしたときは起動し直す．
+type ToolPool struct {
+	// Name : ログに出す名前
+	Name string
+	// Timeout : 1回の要求の制限時間(0ならば無制限)
+	Timeout time.Duration
+	// Workers : 起動したコマンド
+	Workers []*ToolWorker
+
+	idle chan *ToolWorker
+}
+
+// toolResult : ToolWorkerから読んだ結果
+type toolResult struct {
+	lines []string
+	err   error
+}
+
+// NewToolPool : constructor
+// command: sh -cに渡すコマンド
+// n: 起動する数(1未満ならば1)
+func NewToolPool(name string, command string, n int, timeout time.Duration) (*ToolPool, error) {
+	c := strings.Split(command, " ")[0]
+	err := exec.Command("which", c).Run()
+	if err != nil {
+		return nil, errors.New("command not found: " + c)
+	}
+	if n < 1 {
+		n = 1
+	}
+	ret := new(ToolPool)
+	ret.Name = name
+	ret.Timeout = timeout
+	ret.Workers = make([]*ToolWorker, n)
+	ret.idle = make(chan *ToolWorker, n)
+	for i := range ret.Workers {
+		w := &ToolWorker{Command: command}
+		err = w.start()
+		if err != nil {
+			ret.Close()
+			return nil, err
+		}
+		ret.Workers[i] = w
+		ret.idle <- w
+	}
+	return ret, nil
+}
+
+// Execute : 空いているワーカーに1行書き込み，doneがtrueを返すまで出力を1行ずつ読む
+// 最初の1行はptyのエコーなので読み飛ばす．
+// 空いているワーカーがないときは，どれかが空くまで待つ．
+// 時間切れのときはErrToolTimeout，コマンドが異常終了したときはErrToolCrashedを返す．
+func (p *ToolPool) Execute(text string, done func(line string) bool) ([]string, error) {
+	w := <-p.idle
+	defer func() { p.idle <- w }()
+	if w.Alive() == false {
+		log.Warnf("%v: command is not running, restarting", p.Name)
+		err := w.restart()
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v: %w", p.Name, err.Error(), ErrToolCrashed)
+		}
+	}
+
+	_, err := w.pipe.Write([]byte(text + "\n"))
+	if err != nil {
+		p.recover(w)
+		return nil, fmt.Errorf("%v: %v: %w", p.Name, err.Error(), ErrToolCrashed)
+	}
+	result := make(chan toolResult, 1)
+	go func(r *bufio.Reader) {
+		lines, err := readToolLines(r, done)
+		result <- toolResult{lines: lines, err: err}
+	}(w.reader)
+
+	var timeout <-chan time.Time
+	if p.Timeout > 0 {
+		timer := time.NewTimer(p.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case r := <-result:
+		if r.err != nil {
+			p.recover(w)
+			return nil, fmt.Errorf("%v: %v: %w", p.Name, r.err.Error(), ErrToolCrashed)
+		}
+		return r.lines, nil
+	case <-timeout:
+		// 読んでいるgoroutineは，ptyが閉じられたときに終了する
+		p.recover(w)
+		return nil, fmt.Errorf("%v: no response in %v: %w", p.Name, p.Timeout, ErrToolTimeout)
+	}
+}
+
+// recover : ワーカーを起動し直す．失敗したときは次の要求のときにもう一度起動する
+func (p *ToolPool) recover(w *ToolWorker) {
+	log.Warnf("%v: restarting command: %v", p.Name, w.Command)
+	err := w.restart()
+	if err != nil {
+		log.Warnf("%v: failed to restart: %v", p.Name, err.Error())
+	}
+}
+
+// Close : すべてのワーカーを終了する
+func (p *ToolPool) Close() {
+	for _, w := range p.Workers {
+		if w != nil {
+			w.stop()
+		}
+	}
+}
+
+// readToolLines : エコーを読み飛ばして，doneがtrueを返すまで行を読む
+func readToolLines(r *bufio.Reader, done func(line string) bool) ([]string, error) {
+	ret := make([]string, 0)
+	echo := true
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return ret, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if echo {
+			echo = false
+			continue
+		}
+		ret = append(ret, line)
+		if done(line) {
+			return ret, nil
+		}
+	}
+}
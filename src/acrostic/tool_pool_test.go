@@ -0,0 +1,66 @@
+package acrostic
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func tFirstLine(string) bool { return true }
+
+func TestToolPoolExecute(t *testing.T) {
+	p, err := NewToolPool("cat", "cat", 2, 5*time.Second)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer p.Close()
+	var wg sync.WaitGroup
+	for _, s := range []string{"みかん", "りんご", "ぶどう", "なし"} {
+		wg.Add(1)
+		go func(s string) {
+			defer wg.Done()
+			lines, err := p.Execute(s, tFirstLine)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(lines) != 1 || lines[0] != s {
+				t.Errorf("want [%v], but returned %v", s, lines)
+			}
+		}(s)
+	}
+	wg.Wait()
+}
+
+func TestToolPoolTimeout(t *testing.T) {
+	// 入力を読むが，何も出力しない
+	p, err := NewToolPool("silent", "cat > /dev/null", 1, 200*time.Millisecond)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer p.Close()
+	_, err = p.Execute("みかん", tFirstLine)
+	if !errors.Is(err, ErrToolTimeout) {
+		t.Errorf("want ErrToolTimeout, but returned %v", err)
+	}
+	if p.Workers[0].Restarts != 1 || p.Workers[0].Alive() == false {
+		t.Errorf("want restarted worker, but restarts=%v", p.Workers[0].Restarts)
+	}
+}
+
+func TestToolPoolCrash(t *testing.T) {
+	// 1行読んだら何も出力せずに終了する
+	p, err := NewToolPool("crash", "head -n 1 > /dev/null", 1, 5*time.Second)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer p.Close()
+	_, err = p.Execute("みかん", tFirstLine)
+	if !errors.Is(err, ErrToolCrashed) {
+		t.Errorf("want ErrToolCrashed, but returned %v", err)
+	}
+	if p.Workers[0].Restarts == 0 {
+		t.Errorf("want restarted worker")
+	}
+}
@@ -2,6 +2,7 @@ package acrostic
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -33,8 +34,7 @@ func (w WordNetAnswer) String() string {
 	case WNASynonyms:
 		return "synonyms"
 	}
-	log.Fatalf("WordNetAnswer: unknown value")
-	return ""
+	return fmt.Sprintf("WordNetAnswer(%d)", int(w))
 }
 
 func NewWordNetAnswer(s string) WordNetAnswer {
@@ -102,7 +102,7 @@ func NewWordNet(o *Options, i *Instance) (*WordNet, error) {
 		return nil, err
 	}
 	if err = ret.DB.Ping(); err != nil {
-		log.Fatalf("ping failure to sqlite(%v): %v", ret.Options.WordNetDatabase, err.Error())
+		return nil, fmt.Errorf("ping failure to sqlite(%v): %v", ret.Options.WordNetDatabase, err.Error())
 	}
 
 	if o.JumanDirectory == "" {
@@ -112,6 +112,11 @@ func NewWordNet(o *Options, i *Instance) (*WordNet, error) {
 	return ret, nil
 }
 
+// Close : データベースを閉じる
+func (w *WordNet) Close() error {
+	return w.DB.Close()
+}
+
 // getSynset : 指定された単語の指定された関係である単語を取得する．
 // bphrase: 基本句
 // link: 基本句に対するリンク
@@ -178,7 +183,36 @@ func (w *WordNet) getSynset(
 	return ret, nil
 }
 
+// GetSynonyms : 基本句の類語を取得する．永続キャッシュがあればそれを使う
 func (w *WordNet) GetSynonyms(bp *BasicPhrase, link []WordNetLink) ([]WordNetResult, error) {
+	// 対話的に選ぶときやSynsetListで選ぶときは，結果が選択によって変わるのでキャッシュしない
+	if w.Options.Interactive || w.Options.UseSynsetList || w.Instance.Cache == nil {
+		return w.getSynonyms(bp, link)
+	}
+	key := fmt.Sprintf("%v\t%v\t%v\t%v", string(bp.Origin), ToWordNetPart(bp.Part).String(),
+		string(bp.InflectionForm), link)
+	if v, ok := w.Instance.Cache.Get(CacheSynonyms, key); ok {
+		var ret []WordNetResult
+		err := json.Unmarshal([]byte(v), &ret)
+		if err == nil {
+			return ret, nil
+		}
+		log.Warnf("cache: %v", err.Error())
+	}
+	ret, err := w.getSynonyms(bp, link)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(ret)
+	if err != nil {
+		log.Warnf("cache: %v", err.Error())
+	} else {
+		w.Instance.Cache.Set(CacheSynonyms, key, string(b))
+	}
+	return ret, nil
+}
+
+func (w *WordNet) getSynonyms(bp *BasicPhrase, link []WordNetLink) ([]WordNetResult, error) {
 	T, _ := i18n.Tfunc(w.Options.Language)
 	part := ToWordNetPart(bp.Part)
 
@@ -370,7 +404,8 @@ func (w *WordNet) PrintAnswer() {
 		}
 		f, err := os.Create(filename)
 		if err != nil {
-			log.Fatalf(T("unable to create file")+": %v", filename)
+			log.Errorf(T("unable to create file")+": %v", filename)
+			return
 		}
 		defer f.Close()
 		f.WriteString(out)
@@ -1,21 +1,21 @@
 package acrostic
 
-import log "github.com/sirupsen/logrus"
+import "fmt"
 
 // http://compling.hss.ntu.edu.sg/wnja/
 type WordNetLink int
 
-func NewWordNetLink(t string) WordNetLink {
+// NewWordNetLink : 名前からWordNetLinkを作る．不明な名前ならばErrUnknownWordNetLinkを返す
+func NewWordNetLink(t string) (WordNetLink, error) {
 	switch t {
 	case "synonyms":
-		return WNSynonym
+		return WNSynonym, nil
 	case "hype":
-		return WNHype
+		return WNHype, nil
 	case "hypo":
-		return WNHypo
+		return WNHypo, nil
 	}
-	log.Fatalf("not implemented: value of %v", t)
-	return WNEnd
+	return WNEnd, fmt.Errorf("%w: %v", ErrUnknownWordNetLink, t)
 }
 
 const (
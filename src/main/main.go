@@ -0,0 +1,66 @@
+package main
+
+import (
+	"acrostic"
+	"errors"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func main() {
+	o, err := acrostic.NewOptions()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if o.Command != "" {
+		// cache, serve, merge, batch, eval
+		err = acrostic.ExecuteCommand(o)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	os.Exit(run(o))
+}
+
+// run : テキストを縦読み化して，終了コードを返す
+// --codeのときは，見つからなかったときに1を返す
+func run(o *acrostic.Options) int {
+	v, err := acrostic.NewVertical(o)
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+	defer v.Instance.Close()
+	if err = v.ReadKeyword(); err != nil {
+		log.Error(err)
+		return 1
+	}
+	if err = v.ReadText(); err != nil {
+		log.Error(err)
+		return 1
+	}
+	err = v.Analyze()
+	if errors.Is(err, acrostic.ErrKeywordNotFound) {
+		if o.ExitCode {
+			return 1
+		}
+		return 0
+	}
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+	if o.KnpOnly {
+		return 0
+	}
+	if err = v.Generate(); err != nil {
+		log.Error(err)
+		return 1
+	}
+	if o.ExitCode && v.Found == false {
+		return 1
+	}
+	return 0
+}